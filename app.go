@@ -0,0 +1,318 @@
+package main
+
+import (
+	"flag"
+	"math/rand/v2"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/tomanta/echip8/config"
+	"github.com/tomanta/echip8/movie"
+	"github.com/tomanta/echip8/romdb"
+	"github.com/tomanta/echip8/romfile"
+)
+
+const (
+	appTitle   = "gchip"
+	menuKey    = ebiten.KeyEscape // returns from a game to the launcher
+	rebindKey  = ebiten.KeyF1     // opens the keymap screen in a game
+	effectKey  = ebiten.KeyF2     // cycles through the display effects
+	paletteKey = ebiten.KeyF3     // cycles through the palettes
+	statsKey   = ebiten.KeyF6     // shows and hides the statistics overlay
+	fullKey    = ebiten.KeyF11    // switches between a window and fullscreen
+	resetKey   = ebiten.KeyF12    // reboots the game
+
+	// Speed controls in a game
+	pauseKey   = ebiten.KeyF4  // pauses and resumes
+	stepKey    = ebiten.KeyF5  // runs a single frame, pausing first
+	fastKey    = ebiten.KeyTab // fast-forwards while held
+	turboKey   = ebiten.KeyF7  // switches running as fast as possible on and off
+	slowKey    = ebiten.KeyF8  // switches slow motion on and off
+	ipfDownKey = ebiten.KeyF9  // runs fewer instructions per frame
+	ipfUpKey   = ebiten.KeyF10 // runs more instructions per frame
+)
+
+// App is the ebiten.Game for the window. It shows the launcher until a ROM is
+// picked and then runs that ROM until the menu key is pressed.
+type App struct {
+	launcher *Launcher
+	game     *Game
+	rebinder *Rebinder // open on top of the game, which is paused
+	romName  string
+	global   config.Settings // settings before any ROM is chosen
+
+	// Command line flags, applied to every ROM that is booted
+	flags *settingsFlags
+	fs    *flag.FlagSet
+
+	// Movie options for the first ROM booted, cleared once used
+	recordPath string
+	replay     *movie.Movie
+	recording  string // where the running game's movie is saved when it stops
+}
+
+func newApp(flags *settingsFlags, fs *flag.FlagSet) (*App, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		dir = ""
+	}
+	global, err := config.Global(dir)
+	if err != nil {
+		return nil, err
+	}
+	file, err := flags.file(fs)
+	if err != nil {
+		return nil, err
+	}
+	if err := global.Apply("command line", file); err != nil {
+		return nil, err
+	}
+	return &App{global: global, flags: flags, fs: fs}, nil
+}
+
+// boot resets the emulator with the ROM and switches to it
+func (a *App) boot(rom romfile.ROM) error {
+	settings, err := loadSettings(rom, romdb.Lookup(rom.Data), a.flags, a.fs)
+	if err != nil {
+		return err
+	}
+	emu, err := newEmulator(rom, settings)
+	if err != nil {
+		return err
+	}
+	if a.replay != nil {
+		// The movie decides everything that changes the run
+		if emu, err = a.replay.NewEmulator(rom.Data); err != nil {
+			return err
+		}
+		settings.Tickrate = a.replay.Tickrate
+	}
+	game, err := newGame(emu, settings)
+	if err != nil {
+		return err
+	}
+	if a.replay != nil {
+		game.replay = a.replay
+		game.player = movie.NewPlayer(a.replay)
+	}
+	if a.recordPath != "" {
+		seed := rand.Uint64()
+		game.emu.Seed(seed)
+		game.recorder = movie.NewRecorder(rom.Data, game.emu.Quirks, game.runner.IPF(), seed)
+	}
+
+	if err := a.stopGame(); err != nil {
+		return err
+	}
+	a.game = game
+	a.romName = rom.Name
+	a.recording, a.recordPath = a.recordPath, ""
+	a.replay = nil
+	title := settings.Window.Title
+	if title == "" {
+		title = rom.Name
+	}
+	ebiten.SetWindowTitle(title)
+	game.osd.show("%s", bootMessage(settings, game))
+	return nil
+}
+
+// bootMessage describes how a ROM was started, it is shown when the game starts
+func bootMessage(settings config.Settings, g *Game) string {
+	msg := "Quirks: " + settings.Platform
+	if p, ok := romdb.LookupPlatform(settings.Platform); ok {
+		msg = "Quirks: " + p.Name
+	}
+	switch {
+	case g.player != nil:
+		msg += ", replaying a movie"
+	case g.recorder != nil:
+		msg += ", recording a movie"
+	}
+	return msg
+}
+
+// stopGame throws away the running game, if any, saving its movie if it was recorded
+func (a *App) stopGame() error {
+	if a.game == nil {
+		return nil
+	}
+	if a.game.beeper != nil {
+		a.game.beeper.Close()
+	}
+	var err error
+	if a.game.recorder != nil {
+		err = a.game.recorder.Finish(a.game.emu).Save(a.recording)
+		a.recording = ""
+	}
+	a.game = nil
+	a.rebinder = nil
+	return err
+}
+
+// showLauncher stops the game and returns to the launcher, rescanning the ROMs
+func (a *App) showLauncher() {
+	err := a.stopGame()
+	a.launcher = newLauncher(a.global.Launcher.Dirs)
+	if err != nil {
+		a.launcher.status = err.Error()
+	}
+	ebiten.SetWindowTitle(appTitle)
+}
+
+func (a *App) Update() error {
+	if inpututil.IsKeyJustPressed(fullKey) ||
+		(inpututil.IsKeyJustPressed(ebiten.KeyEnter) && ebiten.IsKeyPressed(ebiten.KeyAlt)) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+		return nil
+	}
+
+	if a.rebinder != nil {
+		a.rebinder.Update()
+		if a.rebinder.closed {
+			a.game.keymap = a.rebinder.keymap
+			a.rebinder = nil
+		}
+		return nil
+	}
+
+	if a.game != nil {
+		if inpututil.IsKeyJustPressed(rebindKey) {
+			a.rebinder = newRebinder(a.game.keymap, a.romName)
+			if a.game.beeper != nil {
+				a.game.beeper.SetOn(false)
+			}
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(effectKey) {
+			a.game.osd.show("Effect %s", a.game.renderer.nextEffect())
+		}
+		if inpututil.IsKeyJustPressed(paletteKey) {
+			a.game.osd.show("Palette %s", a.game.nextPalette())
+		}
+		if inpututil.IsKeyJustPressed(statsKey) {
+			a.game.osd.stats = !a.game.osd.stats
+		}
+		if inpututil.IsKeyJustPressed(menuKey) {
+			a.showLauncher()
+			return nil
+		}
+		if inpututil.IsKeyJustPressed(resetKey) {
+			a.game.reset()
+		}
+		if a.speedKeys() {
+			return nil
+		}
+		return a.game.Update()
+	}
+
+	a.launcher.Update()
+	if a.launcher.selected == "" {
+		return nil
+	}
+	path := a.launcher.selected
+	a.launcher.selected = ""
+	rom, err := romfile.Load(path, nil)
+	if err == nil {
+		err = a.boot(rom)
+	}
+	if err != nil {
+		a.launcher.status = err.Error()
+	}
+	return nil
+}
+
+// speedKeys handles the speed controls of the running game. It returns true when
+// the frame was already run by a frame advance.
+func (a *App) speedKeys() bool {
+	g, r := a.game, a.game.runner
+	speed := speedName(r)
+	switch {
+	case inpututil.IsKeyJustPressed(pauseKey):
+		r.SetPaused(!r.Paused())
+		if r.Paused() {
+			g.osd.show("Paused")
+		} else {
+			g.osd.fault = ""
+			g.osd.show("Running")
+		}
+	case inpututil.IsKeyJustPressed(stepKey):
+		r.SetPaused(true)
+		g.held = g.keymap.Pressed()
+		if err := r.Step(); err != nil {
+			g.fail(err)
+		}
+		g.osd.show("Frame %d", r.Frames())
+		return true
+	case inpututil.IsKeyJustPressed(turboKey):
+		g.turbo = !g.turbo
+	case inpututil.IsKeyJustPressed(slowKey):
+		g.slow = !g.slow
+	case g.recorder != nil || g.player != nil:
+		// A movie has a single tickrate, changing it would desync the replay
+	case inpututil.IsKeyJustPressed(ipfDownKey):
+		r.SetIPF(r.IPF() - max(r.IPF()/10, 1))
+		g.osd.show("%d instructions per frame", r.IPF())
+	case inpututil.IsKeyJustPressed(ipfUpKey):
+		r.SetIPF(r.IPF() + max(r.IPF()/10, 1))
+		g.osd.show("%d instructions per frame", r.IPF())
+	}
+	g.fast = ebiten.IsKeyPressed(fastKey)
+	g.applySpeed()
+	if name := speedName(r); name != speed {
+		g.osd.show("Speed %s", name)
+	}
+	return false
+}
+
+func (a *App) Draw(screen *ebiten.Image) {
+	if a.rebinder != nil {
+		screen.Fill(a.global.Palette.Background())
+		a.rebinder.Draw(screen)
+		return
+	}
+	if a.game != nil {
+		a.game.Draw(screen)
+		return
+	}
+	screen.Fill(a.global.Palette.Background())
+	a.launcher.Draw(screen)
+}
+
+// Layout uses the whole window, Game.Draw scales the display to fit it
+func (a *App) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	return outsideWidth, outsideHeight
+}
+
+// runWindow opens the window, booting rom if it has data and showing the launcher otherwise
+func runWindow(app *App, rom romfile.ROM) error {
+	if rom.Data != nil {
+		if err := app.boot(rom); err != nil {
+			return err
+		}
+	} else {
+		app.showLauncher()
+	}
+	window := app.global.Window
+	if app.game != nil {
+		window = app.game.window
+	}
+	ebiten.SetWindowSize(64*window.Scale, 32*window.Scale)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetFullscreen(window.Fullscreen)
+	if err := ebiten.RunGame(app); err != nil {
+		return err
+	}
+	return app.stopGame()
+}
+
+// launcherCommand shows the launcher, it is run when no command or ROM is given
+func launcherCommand() error {
+	fs := flag.NewFlagSet("gchip", flag.ContinueOnError)
+	var flags settingsFlags
+	app, err := newApp(&flags, fs)
+	if err != nil {
+		return err
+	}
+	return runWindow(app, romfile.ROM{})
+}
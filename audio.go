@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+const sampleRate = 48000
+
+// beeper plays a square wave while the CHIP-8 sound timer is running
+type beeper struct {
+	on        atomic.Bool
+	frequency float64
+	volume    float32
+	phase     float64
+	player    *audio.Player
+}
+
+func newBeeper(frequency, volume float64) (*beeper, error) {
+	b := &beeper{frequency: frequency, volume: float32(volume)}
+	// Only one audio context can exist, it is shared by every ROM that is booted
+	ctx := audio.CurrentContext()
+	if ctx == nil {
+		ctx = audio.NewContext(sampleRate)
+	}
+	player, err := ctx.NewPlayerF32(b)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the buffer short so the beep starts and stops close to the sound timer
+	player.SetBufferSize(50 * time.Millisecond)
+	player.Play()
+	b.player = player
+	return b, nil
+}
+
+// Close stops the tone for good
+func (b *beeper) Close() error {
+	return b.player.Close()
+}
+
+// SetOn starts or stops the tone
+func (b *beeper) SetOn(on bool) {
+	b.on.Store(on)
+}
+
+// Read fills buf with stereo 32 bit float samples, it never runs out
+func (b *beeper) Read(buf []byte) (int, error) {
+	const frameSize = 8 // two channels of four bytes
+	n := len(buf) / frameSize * frameSize
+	on := b.on.Load()
+	for i := 0; i < n; i += frameSize {
+		var v float32
+		if on {
+			v = b.volume
+			if b.phase >= 0.5 {
+				v = -v
+			}
+		}
+		b.phase += b.frequency / sampleRate
+		b.phase -= math.Floor(b.phase)
+
+		bits := math.Float32bits(v)
+		binary.LittleEndian.PutUint32(buf[i:], bits)
+		binary.LittleEndian.PutUint32(buf[i+4:], bits)
+	}
+	return n, nil
+}
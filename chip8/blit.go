@@ -0,0 +1,78 @@
+package chip8
+
+import "math/bits"
+
+// edge is what happens to the part of a sprite drawn past the right or bottom edge
+// of the display
+type edge int
+
+const (
+	clipEdge edge = iota // the part past the edge is not drawn
+	wrapEdge             // the part past the edge is drawn on the opposite side
+)
+
+// plane is a single bit plane of a display that sprites are drawn on
+type plane interface {
+	size() (width, height int)
+	flip(x, y int) bool // toggles the pixel at x, y and reports whether it was on
+}
+
+// sprite is the data of a sprite: height rows of width/8 bytes for every plane it
+// is drawn on, one plane after the other. The most significant bit is the leftmost
+// pixel. CHIP-8 sprites are 8 wide, SCHIP 16x16 sprites 16.
+type sprite struct {
+	data          []byte
+	width, height int // height is at most 64
+}
+
+// collisions is what drawing a sprite ran into
+type collisions struct {
+	rows    int // sprite rows that turned off a pixel in any plane
+	clipped int // sprite rows clipped at the bottom of the display
+}
+
+// blit XORs s onto planes at x, y. The position wraps around the display, the
+// parts of the sprite past the edges are handled as e says. All planes must have
+// the same size.
+func blit(planes []plane, s sprite, x, y int, e edge) collisions {
+	var result collisions
+	if len(planes) == 0 {
+		return result
+	}
+	width, height := planes[0].size()
+	x %= width
+	y %= height
+	stride := s.width / 8
+	var hit uint64 // bit n is set when row n collided
+
+	for p, pl := range planes {
+		data := s.data[p*stride*s.height:]
+		for row := range s.height {
+			y_pos := y + row
+			if y_pos >= height {
+				if e == clipEdge {
+					result.clipped = s.height - row
+					break
+				}
+				y_pos %= height
+			}
+			for col := range s.width {
+				if data[row*stride+col/8]&(0x80>>(col%8)) == 0 {
+					continue
+				}
+				x_pos := x + col
+				if x_pos >= width {
+					if e == clipEdge {
+						break
+					}
+					x_pos %= width
+				}
+				if pl.flip(x_pos, y_pos) {
+					hit |= 1 << row
+				}
+			}
+		}
+	}
+	result.rows = bits.OnesCount64(hit)
+	return result
+}
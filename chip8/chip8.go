@@ -2,75 +2,194 @@ package chip8
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"time"
 )
 
+// Version of the core. Movies record it because a replay is only guaranteed to match
+// on the version that recorded it.
+const Version = "0.1.0"
+
+// Chip8 is the emulator. The constructors return a pointer and it should be passed
+// around as one: a copy of the struct shares Memory with the original. Use Clone
+// for an independent copy.
 type Chip8 struct {
-	Memory       [4096]byte
-	Display      [64][32]bool // A 64 x 32 matrix of which pixels are turned on
-	PC           uint16       // Program counter
-	Index        uint16       // Index register, points to memory locations
+	Memory       []byte // Layout.MemorySize bytes
+	layout       Layout // Where the font and the ROM are in Memory
+	rom          []byte // The ROM as loaded, for Reset
+	font         Font
+	Display      Framebuffer // The display, 64 x 32 pixels with one plane
+	PC           uint16      // Program counter
+	Index        uint16      // Index register, points to memory locations
 	Stack        [16]uint16
 	delayTimer   uint8 // Decrements 60 times per second until reaching 0
 	soundTimer   uint8 // Decrements 60 times per second until reaching 0; should beep
 	timeStart    time.Time
 	tickDuration time.Duration
-	Registers    [16]uint8 // Variable registers, may need to change this
-	keysPressed  []byte    // Holds a list of all pressed keys
+	now          func() time.Time // The clock Update ticks the timers by
+	Registers    [16]uint8        // Variable registers, may need to change this
+	keys         [16]bool         // Which keys are held down
+	keyWait      keyWait          // State of an FX0A waiting for a key
+	drawWait     bool             // A DXYN is waiting for the next frame, see Quirks.DisplayWait
+	vblank       bool             // A frame has started since drawWait was set, so the DXYN can draw
+	cycles       uint64           // Instructions executed
+	rng          rand.PCG         // Source for CXNN, a value so copies of the emulator replay the same numbers
+	seed         uint64           // The last seed, Reset restarts the generator from it
 
 	stackPointer int
 	DebugMsg     string
+	Quirks       Quirks // Interpreter specific behaviour, see Quirks
 }
 
 // NewChip8FromByte takes a slice of bytes and returns a Chip8 emulator with default settings
 // and the ROM loaded into memory
-func NewChip8FromByte(rom []byte) (Chip8, error) {
-	if len(rom) == 0 {
-		return Chip8{}, fmt.Errorf("no rom data provided")
-	}
+func NewChip8FromByte(rom []byte) (*Chip8, error) {
+	return New(rom)
+}
 
-	c := Chip8{
-		PC:           0x200,
-		tickDuration: time.Second / 60,
-	}
+// NewChip8WithLayout is NewChip8FromByte with the font, the ROM and the memory size
+// placed as layout says. It returns an error if they don't fit.
+func NewChip8WithLayout(rom []byte, layout Layout) (*Chip8, error) {
+	return New(rom, WithLayout(layout))
+}
 
-	// Copy the rom data into memory
-	for i, byt := range rom {
-		c.Memory[0x200+i] = byt
-	}
+// Layout returns where the font and the ROM were loaded
+func (c *Chip8) Layout() Layout {
+	return c.layout
+}
 
-	c.loadFonts()
-	return c, nil
-}
-
-var fonts = [...]byte{
-	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-	0x20, 0x60, 0x20, 0x20, 0x70, // 1
-	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+// Clone returns a copy of the emulator with its own memory
+func (c *Chip8) Clone() *Chip8 {
+	clone := *c
+	clone.Memory = append([]byte(nil), c.Memory...)
+	return &clone
 }
 
+// loadFonts copies the font into memory, the big font right after the small one
 func (c *Chip8) loadFonts() {
-	var start_mem int = 0x50
-	for i := range fonts {
-		c.Memory[start_mem+i] = fonts[i]
+	n := copy(c.Memory[c.layout.FontAddress:], c.font.Small)
+	copy(c.Memory[(int)(c.layout.FontAddress)+n:], c.font.Big)
+}
+
+// Font returns the font loaded into memory
+func (c *Chip8) Font() Font {
+	return c.font
+}
+
+// SetFont replaces the font in memory. It returns an error if the font is not the
+// right size or doesn't fit at the font address of the layout.
+func (c *Chip8) SetFont(f Font) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	if err := c.layout.validate(len(c.rom), f.size()); err != nil {
+		return err
 	}
+	clear(c.Memory[c.layout.FontAddress : (int)(c.layout.FontAddress)+c.font.size()])
+	c.font = f
+	c.loadFonts()
+	return nil
+}
+
+// keyWait tracks FX0A, which waits for a key to be pressed and released
+type keyWait struct {
+	active bool     // an FX0A is waiting
+	key    int      // the key pressed while waiting, -1 until one is
+	done   bool     // the key has been released (or pressed, with the KeyPress quirk)
+	stale  [16]bool // keys already held when the wait started, ignored until released
 }
 
+// SetKeysPressed sets all keys that are held down, keys not in the slice are released.
+// Frontends that poll the keyboard call this every update; it turns the changes since
+// the last call into KeyDown and KeyUp events.
 func (c *Chip8) SetKeysPressed(keys []byte) {
-	c.keysPressed = keys
+	var held [16]bool
+	for _, k := range keys {
+		held[k&0x0F] = true
+	}
+	for k := range held {
+		if held[k] && !c.keys[k] {
+			c.KeyDown(byte(k))
+		} else if !held[k] && c.keys[k] {
+			c.KeyUp(byte(k))
+		}
+	}
+}
+
+// KeyDown presses a key. Frontends that get key events can call this and KeyUp
+// instead of SetKeysPressed, so presses shorter than an instruction are not lost.
+func (c *Chip8) KeyDown(key byte) {
+	key &= 0x0F
+	c.keys[key] = true
+	if c.keyWait.active && c.keyWait.key < 0 && !c.keyWait.stale[key] {
+		c.keyWait.key = int(key)
+		c.keyWait.done = c.Quirks.KeyPress
+	}
+}
+
+// KeyUp releases a key
+func (c *Chip8) KeyUp(key byte) {
+	key &= 0x0F
+	c.keys[key] = false
+	c.keyWait.stale[key] = false
+	if c.keyWait.active && c.keyWait.key == int(key) {
+		c.keyWait.done = true
+	}
+}
+
+// WaitingForKey reports whether the program is blocked in FX0A
+func (c *Chip8) WaitingForKey() bool {
+	return c.keyWait.active
+}
+
+// WaitingForDisplay reports whether a DXYN is blocked until the next frame by the
+// DisplayWait quirk
+func (c *Chip8) WaitingForDisplay() bool {
+	return c.drawWait
+}
+
+// Cycles returns the number of instructions executed
+func (c *Chip8) Cycles() uint64 {
+	return c.cycles
+}
+
+// StackDepth returns the number of return addresses on the stack
+func (c *Chip8) StackDepth() int {
+	return c.stackPointer
+}
+
+// Timers returns the delay and sound timers
+func (c *Chip8) Timers() (delay, sound uint8) {
+	return c.delayTimer, c.soundTimer
+}
+
+// Beeping reports whether the sound timer is running, which is when the buzzer should sound
+func (c *Chip8) Beeping() bool {
+	return c.soundTimer > 0
+}
+
+// Seed restarts the random number generator used by CXNN. Two emulators with the same
+// ROM, seed and input produce the same run.
+func (c *Chip8) Seed(seed uint64) {
+	c.seed = seed
+	c.rng.Seed(seed, seed)
+}
+
+// RunFrame runs one 60 Hz frame: the given number of instructions and then one tick of
+// the delay and sound timers. Unlike Update it does not look at the host clock, so the
+// run only depends on the ROM, the seed and the keys set before each frame. With the
+// DisplayWait quirk the frame ends early when a DXYN starts waiting for the next one.
+func (c *Chip8) RunFrame(instructions int) error {
+	for range instructions {
+		if err := c.step(); err != nil {
+			return err
+		}
+		if c.drawWait {
+			break
+		}
+	}
+	c.tickTimers()
+	return nil
 }
 
 // Update will process the next instruction. If more than a second has passed since the last tick
@@ -79,16 +198,30 @@ func (c *Chip8) SetKeysPressed(keys []byte) {
 // Note that on a very slow process such as stepping through instructions the timers will still only
 // count down at most once per execution.
 func (c *Chip8) Update() error {
-	if time.Since(c.timeStart) > c.tickDuration {
-		if c.delayTimer > 0 {
-			c.delayTimer -= 1
-		}
+	if now := c.now(); now.Sub(c.timeStart) > c.tickDuration {
+		c.tickTimers()
+		c.timeStart = now // start the new tick
+	}
+	return c.step()
+}
 
-		if c.soundTimer > 0 {
-			c.soundTimer -= 1
-		}
-		c.timeStart = time.Now() // start the new tick
+// tickTimers counts the delay and sound timers down by one. It marks the start of a
+// frame, which lets a waiting DXYN draw.
+func (c *Chip8) tickTimers() {
+	if c.drawWait {
+		c.vblank = true
+	}
+	if c.delayTimer > 0 {
+		c.delayTimer -= 1
+	}
+
+	if c.soundTimer > 0 {
+		c.soundTimer -= 1
 	}
+}
+
+// step fetches and executes a single instruction
+func (c *Chip8) step() error {
 	instruction, err := c.fetch()
 	if err != nil {
 		return err
@@ -99,12 +232,13 @@ func (c *Chip8) Update() error {
 		return err
 	}
 
+	c.cycles++
 	return nil
 }
 
 // fetch the next instruction
 func (c *Chip8) fetch() (uint16, error) {
-	if (int)(c.PC+2) > len(c.Memory) {
+	if (int)(c.PC)+2 > len(c.Memory) {
 		return 0, fmt.Errorf("out of memory! program counter at: %d", c.PC)
 	}
 
@@ -134,7 +268,9 @@ func (c *Chip8) execute(instruction uint16) error {
 	case 0x1000:
 		c.op1NNN(NNN)
 	case 0x2000:
-		c.op2NNN(c.PC)
+		if err := c.op2NNN(c.PC); err != nil {
+			return err
+		}
 		c.PC = NNN
 	case 0x3000:
 		c.op3XNN(X, NN)
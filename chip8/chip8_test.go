@@ -1,9 +1,13 @@
 package chip8
 
 import (
+	"bytes"
+	"image"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -21,7 +25,7 @@ func openTestRom(t testing.TB) []byte {
 	return data
 }
 
-func getIBMEmulator(t testing.TB) Chip8 {
+func getIBMEmulator(t testing.TB) *Chip8 {
 	t.Helper()
 	romData := openTestRom(t)
 
@@ -85,7 +89,8 @@ func TestCanCreateFromBytes(t *testing.T) {
 
 	t.Run("initial display is blank", func(t *testing.T) {
 		want := [64][32]bool{}
-		got := getIBMEmulator(t).Display
+		display := getIBMEmulator(t).Display
+		got := display.Matrix()
 
 		if !reflect.DeepEqual(got, want) {
 			t.Errorf("display not blank")
@@ -119,13 +124,11 @@ func TestErrorsOnBadInstruction(t *testing.T) {
 
 func TestOp00E0(t *testing.T) {
 	var want [64][32]bool
-	var dirtyDisplay [64][32]bool
-	dirtyDisplay[5][1] = true
 
 	emu := getIBMEmulator(t)
-	emu.Display = dirtyDisplay
+	emu.Display.Set(0, 5, 1, true)
 	emu.Update()
-	got := emu.Display
+	got := emu.Display.Matrix()
 	if !reflect.DeepEqual(got, want) {
 		t.Error("0x00E0 instruction did not clear display")
 	}
@@ -159,46 +162,45 @@ var cases = []struct {
 	rom         []byte
 	num_updates int
 	want        uint16
-	got         func(emu Chip8) uint16
+	got         func(emu *Chip8) uint16
 }{
-	{name: "op1NNN jumps to memory location NNN", rom: []byte{0x12, 0x34}, num_updates: 1, want: 0x0234, got: func(emu Chip8) uint16 { return emu.PC }},
-	{name: "op8XY0 sets X to Y", rom: []byte{0x61, 0x82, 0x62, 0x85, 0x81, 0x20}, num_updates: 3, want: 0x85, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY1 binary OR X and Y", rom: []byte{0x61, 0x45, 0x62, 0x32, 0x81, 0x21}, num_updates: 3, want: 0x45 | 0x32, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY2 binary AND X and Y", rom: []byte{0x61, 0x45, 0x62, 0x42, 0x81, 0x22}, num_updates: 3, want: 0x45 & 0x42, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY3 binary XOR X and Y", rom: []byte{0x61, 0x45, 0x62, 0x42, 0x81, 0x23}, num_updates: 3, want: 0x45 ^ 0x42, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY4 adds X and Y into X", rom: []byte{0x61, 0x45, 0x62, 0x42, 0x81, 0x24}, num_updates: 3, want: 0x45 + 0x42, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY4 sets overflow flag = 1 if overflow", rom: []byte{0x61, 0xBB, 0x62, 0x88, 0x81, 0x24}, num_updates: 3, want: 0x01, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
-	{name: "op8XY5 subtracts Y from X and stores into X", rom: []byte{0x61, 0x88, 0x62, 0x42, 0x81, 0x25}, num_updates: 3, want: 0x88 - 0x42, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY5 does not set overflow flag", rom: []byte{0x61, 0xBB, 0x62, 0x88, 0x81, 0x25}, num_updates: 3, want: 0x00, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
-	{name: "op8XY6 shifts Y one bit to right, stores in X", rom: []byte{0x62, 0x10, 0x81, 0x26}, num_updates: 2, want: 0x10 >> 1, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XY6 sets flag to 0 if right bit is 0", rom: []byte{0x6F, 0x01, 0x62, 0x10, 0x81, 0x26}, num_updates: 3, want: 0, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
-	{name: "op8XY6 sets flag to 1 if right bit is 1", rom: []byte{0x62, 0x11, 0x81, 0x26}, num_updates: 2, want: 1, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
-	{name: "op8XY7 subtracts Y from X and stores into X", rom: []byte{0x61, 0x88, 0x62, 0x42, 0x82, 0x17}, num_updates: 3, want: 0x88 - 0x42, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[2]) }},
-	{name: "op8XY7 does not set underflow if X > Y flag", rom: []byte{0x6F, 0x01, 0x61, 0xBB, 0x62, 0x88, 0x82, 0x17}, num_updates: 4, want: 0x00, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
-	{name: "op8XY7 does set underflow flag if X < Y flag", rom: []byte{0x61, 0x88, 0x62, 0xBB, 0x82, 0x17}, num_updates: 3, want: 0x01, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
-	{name: "op8XYE shifts Y one bit to left, stores in X", rom: []byte{0x62, 0xAA, 0x81, 0x2E}, num_updates: 2, want: 0x54, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
-	{name: "op8XYE sets flag to 0 if left bit is 0", rom: []byte{0x6F, 0x01, 0x62, 0x10, 0x81, 0x2E}, num_updates: 3, want: 0, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
-	{name: "op8XYE sets flag to 1 if left bit is 1", rom: []byte{0x62, 0x80, 0x81, 0x2E}, num_updates: 2, want: 1, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
-	{name: "opBNNN jumps to NNN plus value in V0", rom: []byte{0x60, 0x20, 0xB3, 0x00}, num_updates: 2, want: 0x320, got: func(emu Chip8) uint16 { return emu.PC }},
+	{name: "op1NNN jumps to memory location NNN", rom: []byte{0x12, 0x34}, num_updates: 1, want: 0x0234, got: func(emu *Chip8) uint16 { return emu.PC }},
+	{name: "op8XY0 sets X to Y", rom: []byte{0x61, 0x82, 0x62, 0x85, 0x81, 0x20}, num_updates: 3, want: 0x85, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY1 binary OR X and Y", rom: []byte{0x61, 0x45, 0x62, 0x32, 0x81, 0x21}, num_updates: 3, want: 0x45 | 0x32, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY2 binary AND X and Y", rom: []byte{0x61, 0x45, 0x62, 0x42, 0x81, 0x22}, num_updates: 3, want: 0x45 & 0x42, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY3 binary XOR X and Y", rom: []byte{0x61, 0x45, 0x62, 0x42, 0x81, 0x23}, num_updates: 3, want: 0x45 ^ 0x42, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY4 adds X and Y into X", rom: []byte{0x61, 0x45, 0x62, 0x42, 0x81, 0x24}, num_updates: 3, want: 0x45 + 0x42, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY4 sets overflow flag = 1 if overflow", rom: []byte{0x61, 0xBB, 0x62, 0x88, 0x81, 0x24}, num_updates: 3, want: 0x01, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY5 subtracts Y from X and stores into X", rom: []byte{0x61, 0x88, 0x62, 0x42, 0x81, 0x25}, num_updates: 3, want: 0x88 - 0x42, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY5 does not set overflow flag", rom: []byte{0x61, 0xBB, 0x62, 0x88, 0x81, 0x25}, num_updates: 3, want: 0x00, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY6 shifts Y one bit to right, stores in X", rom: []byte{0x62, 0x10, 0x81, 0x26}, num_updates: 2, want: 0x10 >> 1, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XY6 sets flag to 0 if right bit is 0", rom: []byte{0x6F, 0x01, 0x62, 0x10, 0x81, 0x26}, num_updates: 3, want: 0, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
+	{name: "op8XY6 sets flag to 1 if right bit is 1", rom: []byte{0x62, 0x11, 0x81, 0x26}, num_updates: 2, want: 1, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
+	{name: "op8XY7 subtracts Y from X and stores into X", rom: []byte{0x61, 0x88, 0x62, 0x42, 0x82, 0x17}, num_updates: 3, want: 0x88 - 0x42, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[2]) }},
+	{name: "op8XY7 does not set underflow if X > Y flag", rom: []byte{0x6F, 0x01, 0x61, 0xBB, 0x62, 0x88, 0x82, 0x17}, num_updates: 4, want: 0x00, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY7 does set underflow flag if X < Y flag", rom: []byte{0x61, 0x88, 0x62, 0xBB, 0x82, 0x17}, num_updates: 3, want: 0x01, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XYE shifts Y one bit to left, stores in X", rom: []byte{0x62, 0xAA, 0x81, 0x2E}, num_updates: 2, want: 0x54, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XYE sets flag to 0 if left bit is 0", rom: []byte{0x6F, 0x01, 0x62, 0x10, 0x81, 0x2E}, num_updates: 3, want: 0, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
+	{name: "op8XYE sets flag to 1 if left bit is 1", rom: []byte{0x62, 0x80, 0x81, 0x2E}, num_updates: 2, want: 1, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xf]) }},
+	{name: "opBNNN jumps to NNN plus value in V0", rom: []byte{0x60, 0x20, 0xB3, 0x00}, num_updates: 2, want: 0x320, got: func(emu *Chip8) uint16 { return emu.PC }},
 	// todo: opEX9E
 	// todo: opEXA1
-	{name: "opFX07 sets VX to value of delay timer", rom: []byte{0x61, 0x30, 0xF1, 0x15, 0xF2, 0x07}, num_updates: 3, want: 0x30, got: func(emu Chip8) uint16 { return uint16(emu.Registers[0x02]) }},
-	{name: "opFX15 sets delay timer to value of X", rom: []byte{0x61, 0x30, 0xF1, 0x15}, num_updates: 2, want: 0x30, got: func(emu Chip8) uint16 { return uint16(emu.delayTimer) }},
-	{name: "opFX18 sets sound timer to value of X", rom: []byte{0x61, 0x30, 0xF1, 0x18}, num_updates: 2, want: 0x30, got: func(emu Chip8) uint16 { return uint16(emu.soundTimer) }},
-	{name: "opFX1E adds the value of VX to Index", rom: []byte{0xA1, 0x11, 0x61, 0x22, 0xF1, 0x1E}, num_updates: 3, want: 0x133, got: func(emu Chip8) uint16 { return uint16(emu.Index) }},
-	{name: "opFX1E sets overflow bit if overflow", rom: []byte{0xAF, 0x88, 0x61, 0x99, 0xF1, 0x1E}, num_updates: 3, want: 0x1, got: func(emu Chip8) uint16 { return uint16(emu.Registers[0xF]) }},
-	{name: "opFX1E sets index correct if overflow", rom: []byte{0xAF, 0x88, 0x61, 0x99, 0xF1, 0x1E}, num_updates: 3, want: 0x021, got: func(emu Chip8) uint16 { return uint16(emu.Index) }},
-	{name: "opFX1E does not set overflow bit if not overflow", rom: []byte{0xA1, 0x11, 0x61, 0x22, 0xF1, 0x1E}, num_updates: 3, want: 0x0, got: func(emu Chip8) uint16 { return uint16(emu.Registers[0xF]) }},
-	// todo: opFX0A
-	{name: "opFX29 sets index to font 1", rom: []byte{0x61, 0x01, 0xF1, 0x29}, num_updates: 2, want: 0x0055, got: func(emu Chip8) uint16 { return emu.Index }},
-	{name: "opFX29 sets index to font 0", rom: []byte{0x61, 0x10, 0xF1, 0x29}, num_updates: 2, want: 0x0050, got: func(emu Chip8) uint16 { return emu.Index }},
-	{name: "opFX29 sets index to font F", rom: []byte{0x61, 0x3D, 0xF1, 0x29}, num_updates: 2, want: 0x0091, got: func(emu Chip8) uint16 { return emu.Index }},
-	{name: "opFX29 sets index to font 8", rom: []byte{0x61, 0x08, 0xF1, 0x29}, num_updates: 2, want: 0x0078, got: func(emu Chip8) uint16 { return emu.Index }},
-	{name: "opFX33 splits value in VX to 3 digits and stores starting at Index; first digit", rom: []byte{0x61, 0x9C, 0xA5, 0x50, 0xF1, 0x33}, num_updates: 3, want: 1, got: func(emu Chip8) uint16 { return (uint16)(emu.Memory[emu.Index]) }},
-	{name: "opFX33 splits value in VX to 3 digits and stores starting at Index; second digit", rom: []byte{0x61, 0x9C, 0xA5, 0x50, 0xF1, 0x33}, num_updates: 3, want: 5, got: func(emu Chip8) uint16 { return (uint16)(emu.Memory[emu.Index+1]) }},
-	{name: "opFX33 splits value in VX to 3 digits and stores starting at Index; third digit", rom: []byte{0x61, 0x9C, 0xA5, 0x50, 0xF1, 0x33}, num_updates: 3, want: 6, got: func(emu Chip8) uint16 { return (uint16)(emu.Memory[emu.Index+2]) }},
-	{name: "opFX55 stores V6 in Index + 6", rom: []byte{0x66, 0x9C, 0xA6, 0x50, 0xF6, 0x55}, num_updates: 3, want: 0x9C, got: func(emu Chip8) uint16 { return (uint16)(emu.Memory[emu.Index+6]) }},
-	{name: "opFX65 loads into VX values up to Index + 6", rom: []byte{0x66, 0x9C, 0xA6, 0x50, 0xF6, 0x55, 0x66, 0x00, 0xF6, 0x65}, num_updates: 5, want: 0x9C, got: func(emu Chip8) uint16 { return (uint16)(emu.Registers[6]) }},
+	{name: "opFX07 sets VX to value of delay timer", rom: []byte{0x61, 0x30, 0xF1, 0x15, 0xF2, 0x07}, num_updates: 3, want: 0x30, got: func(emu *Chip8) uint16 { return uint16(emu.Registers[0x02]) }},
+	{name: "opFX15 sets delay timer to value of X", rom: []byte{0x61, 0x30, 0xF1, 0x15}, num_updates: 2, want: 0x30, got: func(emu *Chip8) uint16 { return uint16(emu.delayTimer) }},
+	{name: "opFX18 sets sound timer to value of X", rom: []byte{0x61, 0x30, 0xF1, 0x18}, num_updates: 2, want: 0x30, got: func(emu *Chip8) uint16 { return uint16(emu.soundTimer) }},
+	{name: "opFX1E adds the value of VX to Index", rom: []byte{0xA1, 0x11, 0x61, 0x22, 0xF1, 0x1E}, num_updates: 3, want: 0x133, got: func(emu *Chip8) uint16 { return uint16(emu.Index) }},
+	{name: "opFX1E sets overflow bit if overflow", rom: []byte{0xAF, 0x88, 0x61, 0x99, 0xF1, 0x1E}, num_updates: 3, want: 0x1, got: func(emu *Chip8) uint16 { return uint16(emu.Registers[0xF]) }},
+	{name: "opFX1E sets index correct if overflow", rom: []byte{0xAF, 0x88, 0x61, 0x99, 0xF1, 0x1E}, num_updates: 3, want: 0x021, got: func(emu *Chip8) uint16 { return uint16(emu.Index) }},
+	{name: "opFX1E does not set overflow bit if not overflow", rom: []byte{0xA1, 0x11, 0x61, 0x22, 0xF1, 0x1E}, num_updates: 3, want: 0x0, got: func(emu *Chip8) uint16 { return uint16(emu.Registers[0xF]) }},
+	{name: "opFX29 sets index to font 1", rom: []byte{0x61, 0x01, 0xF1, 0x29}, num_updates: 2, want: 0x0055, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX29 sets index to font 0", rom: []byte{0x61, 0x10, 0xF1, 0x29}, num_updates: 2, want: 0x0050, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX29 sets index to font F", rom: []byte{0x61, 0x3D, 0xF1, 0x29}, num_updates: 2, want: 0x0091, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX29 sets index to font 8", rom: []byte{0x61, 0x08, 0xF1, 0x29}, num_updates: 2, want: 0x0078, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX33 splits value in VX to 3 digits and stores starting at Index; first digit", rom: []byte{0x61, 0x9C, 0xA5, 0x50, 0xF1, 0x33}, num_updates: 3, want: 1, got: func(emu *Chip8) uint16 { return (uint16)(emu.Memory[emu.Index]) }},
+	{name: "opFX33 splits value in VX to 3 digits and stores starting at Index; second digit", rom: []byte{0x61, 0x9C, 0xA5, 0x50, 0xF1, 0x33}, num_updates: 3, want: 5, got: func(emu *Chip8) uint16 { return (uint16)(emu.Memory[emu.Index+1]) }},
+	{name: "opFX33 splits value in VX to 3 digits and stores starting at Index; third digit", rom: []byte{0x61, 0x9C, 0xA5, 0x50, 0xF1, 0x33}, num_updates: 3, want: 6, got: func(emu *Chip8) uint16 { return (uint16)(emu.Memory[emu.Index+2]) }},
+	{name: "opFX55 stores V6 in Index + 6", rom: []byte{0x66, 0x9C, 0xA6, 0x50, 0xF6, 0x55}, num_updates: 3, want: 0x9C, got: func(emu *Chip8) uint16 { return (uint16)(emu.Memory[emu.Index+6]) }},
+	{name: "opFX65 loads into VX values up to Index + 6", rom: []byte{0x66, 0x9C, 0xA6, 0x50, 0xF6, 0x55, 0x66, 0x00, 0xF6, 0x65}, num_updates: 5, want: 0x9C, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[6]) }},
 }
 
 func TestBasicInstructions(t *testing.T) {
@@ -234,6 +236,18 @@ func TestOp2NNN(t *testing.T) {
 	}
 }
 
+func TestStackOverflow(t *testing.T) {
+	rom := []byte{0x22, 0x00} // CALL 0x200, forever
+	emu, _ := NewChip8FromByte(rom)
+	err := emu.RunFrame(len(emu.Stack) + 1)
+	if err == nil || !strings.Contains(err.Error(), "stack overflow at 0x200") {
+		t.Fatalf("expected a stack overflow error, got %v", err)
+	}
+	if emu.stackPointer != len(emu.Stack) {
+		t.Errorf("expected a full stack, got stack pointer %d", emu.stackPointer)
+	}
+}
+
 func TestOp3XNN(t *testing.T) {
 	rom := []byte{0x61, 0x82, 0x31, 0x82, 0xFF, 0xFF, 0x82, 0xEE}
 	emu, _ := NewChip8FromByte(rom)
@@ -338,3 +352,689 @@ func TestOpANNN(t *testing.T) {
 		t.Errorf("expected index register 0x%03X, got 0x%03X", want, got)
 	}
 }
+
+var quirkCases = []struct {
+	name        string
+	quirks      Quirks
+	rom         []byte
+	num_updates int
+	want        uint16
+	got         func(emu *Chip8) uint16
+}{
+	{name: "op8XY1 leaves VF alone without logic quirk", quirks: Quirks{}, rom: []byte{0x6F, 0x05, 0x61, 0x45, 0x81, 0xF1}, num_updates: 3, want: 0x05, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY1 resets VF with logic quirk", quirks: Quirks{Logic: true}, rom: []byte{0x6F, 0x05, 0x61, 0x45, 0x81, 0x21}, num_updates: 3, want: 0x00, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY2 resets VF with logic quirk", quirks: Quirks{Logic: true}, rom: []byte{0x6F, 0x05, 0x61, 0x45, 0x81, 0x22}, num_updates: 3, want: 0x00, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY3 resets VF with logic quirk", quirks: Quirks{Logic: true}, rom: []byte{0x6F, 0x05, 0x61, 0x45, 0x81, 0x23}, num_updates: 3, want: 0x00, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[0xF]) }},
+	{name: "op8XY6 shifts X in place with shift quirk", quirks: Quirks{Shift: true}, rom: []byte{0x61, 0x10, 0x62, 0x40, 0x81, 0x26}, num_updates: 3, want: 0x10 >> 1, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "op8XYE shifts X in place with shift quirk", quirks: Quirks{Shift: true}, rom: []byte{0x61, 0x10, 0x62, 0x40, 0x81, 0x2E}, num_updates: 3, want: 0x10 << 1, got: func(emu *Chip8) uint16 { return (uint16)(emu.Registers[1]) }},
+	{name: "opBXNN jumps to XNN plus VX with jump quirk", quirks: Quirks{Jump: true}, rom: []byte{0x60, 0x20, 0x63, 0x04, 0xB3, 0x00}, num_updates: 3, want: 0x304, got: func(emu *Chip8) uint16 { return emu.PC }},
+	{name: "opFX55 leaves Index unchanged without memory quirk", quirks: Quirks{}, rom: []byte{0xA6, 0x50, 0xF6, 0x55}, num_updates: 2, want: 0x650, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX55 increments Index by X + 1 with memory quirk", quirks: Quirks{MemoryIncrement: true}, rom: []byte{0xA6, 0x50, 0xF6, 0x55}, num_updates: 2, want: 0x657, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX55 wraps Index at the top of memory with memory quirk", quirks: Quirks{MemoryIncrement: true}, rom: []byte{0xAF, 0xF0, 0xFF, 0x55, 0xFF, 0x65}, num_updates: 3, want: 0x010, got: func(emu *Chip8) uint16 { return emu.Index }},
+	{name: "opFX65 increments Index by X with memory by X quirk", quirks: Quirks{MemoryIncrementByX: true}, rom: []byte{0xA6, 0x50, 0xF6, 0x65}, num_updates: 2, want: 0x656, got: func(emu *Chip8) uint16 { return emu.Index }},
+}
+
+func TestQuirks(t *testing.T) {
+	for _, test := range quirkCases {
+		t.Run(test.name, func(t *testing.T) {
+			emu, _ := NewChip8FromByte(test.rom)
+			emu.Quirks = test.quirks
+			for range test.num_updates {
+				emu.Update()
+			}
+			got := test.got(emu)
+			if got != test.want {
+				t.Errorf("Expected 0x%04X, got 0x%04X", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	cases := []struct {
+		instruction uint16
+		want        string
+	}{
+		{0x00E0, "CLS"},
+		{0x00EE, "RET"},
+		{0x1234, "JP 0x234"},
+		{0x2ABC, "CALL 0xABC"},
+		{0x3A12, "SE VA, 0x12"},
+		{0x5120, "SE V1, V2"},
+		{0x5121, "DW 0x5121"},
+		{0x8AB6, "SHR VA, VB"},
+		{0x8AB8, "DW 0x8AB8"},
+		{0xA22A, "LD I, 0x22A"},
+		{0xD01F, "DRW V0, V1, 15"},
+		{0xE59E, "SKP V5"},
+		{0xF30A, "LD V3, K"},
+		{0xF265, "LD V2, [I]"},
+		{0xFFFF, "DW 0xFFFF"},
+	}
+	for _, test := range cases {
+		got := Disassemble(test.instruction)
+		if got != test.want {
+			t.Errorf("Disassemble(0x%04X): expected %q, got %q", test.instruction, test.want, got)
+		}
+	}
+
+	lines := DisassembleROM([]byte{0x00, 0xE0, 0x12})
+	want := []string{"0x200  00E0  CLS", "0x202  12    DB 0x12"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected %q, got %q", want, lines)
+	}
+}
+
+func TestOpFX0A(t *testing.T) {
+	rom := []byte{0xF3, 0x0A, 0x61, 0x01}
+
+	t.Run("waits while a key is held and stores it on release", func(t *testing.T) {
+		emu, _ := NewChip8FromByte(rom)
+		emu.Update()
+		emu.SetKeysPressed([]byte{0x5})
+		emu.Update()
+		emu.Update()
+		if emu.PC != 0x200 || !emu.WaitingForKey() {
+			t.Fatalf("expected FX0A to wait while the key is held, PC is 0x%03X", emu.PC)
+		}
+		emu.SetKeysPressed(nil)
+		emu.Update()
+		if emu.PC != 0x202 || emu.WaitingForKey() {
+			t.Errorf("expected FX0A to finish on release, PC is 0x%03X", emu.PC)
+		}
+		if emu.Registers[3] != 0x5 {
+			t.Errorf("expected V3 to be 0x5, got 0x%X", emu.Registers[3])
+		}
+	})
+
+	t.Run("ignores other keys released while waiting", func(t *testing.T) {
+		emu, _ := NewChip8FromByte(rom)
+		emu.Update()
+		emu.SetKeysPressed([]byte{0x5})
+		emu.Update()
+		emu.SetKeysPressed([]byte{0x5, 0x7})
+		emu.Update()
+		emu.SetKeysPressed([]byte{0x5})
+		emu.Update()
+		if emu.PC != 0x200 {
+			t.Errorf("expected FX0A to keep waiting for key 5, PC is 0x%03X", emu.PC)
+		}
+	})
+
+	t.Run("press and release between instructions is not lost", func(t *testing.T) {
+		emu, _ := NewChip8FromByte(rom)
+		emu.Update()
+		emu.KeyDown(0xA)
+		emu.KeyUp(0xA)
+		emu.Update()
+		if emu.PC != 0x202 || emu.Registers[3] != 0xA {
+			t.Errorf("expected FX0A to store 0xA, PC is 0x%03X, V3 is 0x%X", emu.PC, emu.Registers[3])
+		}
+	})
+
+	t.Run("a key held across two waits answers only the first", func(t *testing.T) {
+		// LD V3, K; LD V4, K; LD V1, 1
+		emu, _ := NewChip8FromByte([]byte{0xF3, 0x0A, 0xF4, 0x0A, 0x61, 0x01})
+		emu.Quirks.KeyPress = true
+		emu.Update()
+		emu.SetKeysPressed([]byte{0x5})
+		emu.Update()
+		for range 3 {
+			emu.Update()
+		}
+		if emu.PC != 0x202 || emu.Registers[3] != 0x5 {
+			t.Fatalf("expected the second FX0A to wait while 5 is still held, PC is 0x%03X", emu.PC)
+		}
+		emu.SetKeysPressed(nil)
+		emu.Update()
+		if emu.PC != 0x202 {
+			t.Fatalf("expected releasing the held key not to finish the wait, PC is 0x%03X", emu.PC)
+		}
+		emu.SetKeysPressed([]byte{0x5})
+		emu.Update()
+		if emu.PC != 0x204 || emu.Registers[4] != 0x5 {
+			t.Errorf("expected a new press to finish the wait, PC is 0x%03X, V4 is 0x%X", emu.PC, emu.Registers[4])
+		}
+	})
+
+	t.Run("key press quirk finishes on press", func(t *testing.T) {
+		emu, _ := NewChip8FromByte(rom)
+		emu.Quirks.KeyPress = true
+		emu.Update()
+		emu.SetKeysPressed([]byte{0x5})
+		emu.Update()
+		if emu.PC != 0x202 || emu.Registers[3] != 0x5 {
+			t.Errorf("expected FX0A to store 0x5 on press, PC is 0x%03X, V3 is 0x%X", emu.PC, emu.Registers[3])
+		}
+	})
+}
+
+func TestOpEX9EAndEXA1(t *testing.T) {
+	// V1 = 5, skip next if key 5 is pressed, then skip next if key 5 is not pressed
+	rom := []byte{0x61, 0x05, 0xE1, 0x9E, 0x00, 0xE0, 0xE1, 0xA1}
+	emu, _ := NewChip8FromByte(rom)
+	emu.SetKeysPressed([]byte{0x5})
+	emu.Update()
+	emu.Update()
+	if emu.PC != 0x206 {
+		t.Errorf("expected EX9E to skip with key held, PC is 0x%03X", emu.PC)
+	}
+	emu.Update()
+	if emu.PC != 0x208 {
+		t.Errorf("expected EXA1 not to skip with key held, PC is 0x%03X", emu.PC)
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	// LD V1, 3; LD DT, V1; then loop forever
+	rom := []byte{0x61, 0x03, 0xF1, 0x15, 0x12, 0x04}
+	emu, _ := NewChip8FromByte(rom)
+	if err := emu.RunFrame(10); err != nil {
+		t.Fatal(err)
+	}
+	if emu.delayTimer != 2 {
+		t.Errorf("expected the delay timer to tick once per frame, got %d", emu.delayTimer)
+	}
+	emu.RunFrame(10)
+	emu.RunFrame(10)
+	emu.RunFrame(10)
+	if emu.delayTimer != 0 {
+		t.Errorf("expected the delay timer to stop at 0, got %d", emu.delayTimer)
+	}
+}
+
+func TestDisplayWait(t *testing.T) {
+	// Draw the font sprite for 0 three times, then loop forever
+	rom := []byte{0xD0, 0x15, 0xD0, 0x15, 0xD0, 0x15, 0x12, 0x06}
+	cases := []struct {
+		name   string
+		quirks Quirks
+		frames int
+		want   uint16 // PC after the frames
+	}{
+		{name: "draws without waiting", quirks: Quirks{}, frames: 1, want: 0x206},
+		{name: "first draw waits for the next frame", quirks: Quirks{DisplayWait: true}, frames: 1, want: 0x200},
+		{name: "one draw per frame", quirks: Quirks{DisplayWait: true}, frames: 3, want: 0x204},
+		{name: "all draws after four frames", quirks: Quirks{DisplayWait: true}, frames: 4, want: 0x206},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			emu, _ := NewChip8FromByte(rom)
+			emu.Index = 0x50
+			emu.Quirks = test.quirks
+			for range test.frames {
+				if err := emu.RunFrame(10); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if emu.PC != test.want {
+				t.Errorf("expected PC 0x%03X, got 0x%03X", test.want, emu.PC)
+			}
+		})
+	}
+
+	t.Run("reports the wait and ends the frame", func(t *testing.T) {
+		emu, _ := NewChip8FromByte(rom)
+		emu.Index = 0x50
+		emu.Quirks.DisplayWait = true
+		emu.RunFrame(10)
+		if !emu.WaitingForDisplay() || emu.Cycles() != 1 {
+			t.Errorf("expected to wait after one instruction, got waiting %v after %d", emu.WaitingForDisplay(), emu.Cycles())
+		}
+		emu.RunFrame(10)
+		if !emu.Display.Lit(0, 0) || !emu.WaitingForDisplay() {
+			t.Errorf("expected the first sprite drawn and the second waiting")
+		}
+	})
+}
+
+func TestOpDXYNEdges(t *testing.T) {
+	// DRW V0, V1, 2 with I pointing at a 2x2 block after it
+	rom := []byte{0xD0, 0x12, 0xC0, 0xC0}
+	type pixel struct{ x, y int }
+	cases := []struct {
+		name string
+		wrap bool
+		x, y uint8
+		want []pixel
+	}{
+		{name: "inside", x: 10, y: 10, want: []pixel{{10, 10}, {11, 10}, {10, 11}, {11, 11}}},
+		{name: "inside with wrap", wrap: true, x: 10, y: 10, want: []pixel{{10, 10}, {11, 10}, {10, 11}, {11, 11}}},
+		{name: "right edge clips", x: 63, y: 10, want: []pixel{{63, 10}, {63, 11}}},
+		{name: "right edge wraps", wrap: true, x: 63, y: 10, want: []pixel{{63, 10}, {0, 10}, {63, 11}, {0, 11}}},
+		{name: "bottom edge clips", x: 10, y: 31, want: []pixel{{10, 31}, {11, 31}}},
+		{name: "bottom edge wraps", wrap: true, x: 10, y: 31, want: []pixel{{10, 31}, {11, 31}, {10, 0}, {11, 0}}},
+		{name: "corner clips", x: 63, y: 31, want: []pixel{{63, 31}}},
+		{name: "corner wraps", wrap: true, x: 63, y: 31, want: []pixel{{63, 31}, {0, 31}, {63, 0}, {0, 0}}},
+		{name: "start position always wraps", x: 64 + 63, y: 32 + 31, want: []pixel{{63, 31}}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			emu, _ := NewChip8FromByte(rom)
+			emu.Quirks.Wrap = test.wrap
+			emu.Registers[0], emu.Registers[1] = test.x, test.y
+			emu.Index = 0x202
+			if err := emu.RunFrame(1); err != nil {
+				t.Fatal(err)
+			}
+			lit := emu.Display.LitCount()
+			if lit != len(test.want) {
+				t.Errorf("expected %d pixels lit, got %d", len(test.want), lit)
+			}
+			for _, p := range test.want {
+				if !emu.Display.Lit(p.x, p.y) {
+					t.Errorf("expected pixel (%d, %d) to be lit", p.x, p.y)
+				}
+			}
+		})
+	}
+
+	t.Run("wrapped pixels collide", func(t *testing.T) {
+		emu, _ := NewChip8FromByte([]byte{0xD0, 0x12, 0xD0, 0x12, 0xC0, 0xC0})
+		emu.Quirks.Wrap = true
+		emu.Registers[0], emu.Registers[1] = 63, 31
+		emu.Index = 0x204
+		emu.RunFrame(2)
+		if emu.Registers[0xF] != 1 || emu.Display.Lit(0, 0) {
+			t.Errorf("expected drawing twice to collide and erase the sprite, VF %d", emu.Registers[0xF])
+		}
+	})
+}
+
+func TestBlit(t *testing.T) {
+	t.Run("16 wide sprite past the right edge", func(t *testing.T) {
+		d := NewFramebuffer(64, 32, 1)
+		s := sprite{data: []byte{0xFF, 0xFF, 0x80, 0x01}, width: 16, height: 2}
+		blit([]plane{framePlane{&d, 0}}, s, 56, 0, clipEdge)
+		if d.LitCount() != 8+1 || !d.Lit(56, 1) || d.Lit(63, 1) {
+			t.Errorf("expected 8 pixels of the first row and the first of the second, got %d", d.LitCount())
+		}
+		d.Clear()
+		blit([]plane{framePlane{&d, 0}}, s, 56, 0, wrapEdge)
+		if d.LitCount() != 16+2 || !d.Lit(7, 0) || !d.Lit(7, 1) {
+			t.Errorf("expected the whole sprite with the right half wrapped, got %d", d.LitCount())
+		}
+	})
+
+	t.Run("each plane gets its own data", func(t *testing.T) {
+		d := NewFramebuffer(64, 32, 2)
+		s := sprite{data: []byte{0x80, 0x40}, width: 8, height: 1}
+		blit([]plane{framePlane{&d, 0}, framePlane{&d, 1}}, s, 0, 0, clipEdge)
+		if d.Pixel(0, 0) != 1 || d.Pixel(1, 0) != 2 {
+			t.Errorf("expected plane 1 to get the first byte and plane 2 the second, got %d and %d", d.Pixel(0, 0), d.Pixel(1, 0))
+		}
+	})
+
+	t.Run("counts colliding and clipped rows", func(t *testing.T) {
+		d := NewFramebuffer(64, 32, 2)
+		d.Set(0, 0, 29, true) // row 0
+		d.Set(1, 1, 29, true) // row 0 again, in the other plane
+		d.Set(1, 0, 30, true) // row 1
+		s := sprite{data: []byte{0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0}, width: 8, height: 4}
+		got := blit([]plane{framePlane{&d, 0}, framePlane{&d, 1}}, s, 0, 29, clipEdge)
+		want := collisions{rows: 2, clipped: 1}
+		if got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("wraps on a high resolution display", func(t *testing.T) {
+		d := NewFramebuffer(128, 64, 1)
+		s := sprite{data: []byte{0xC0, 0xC0}, width: 8, height: 2}
+		blit([]plane{framePlane{&d, 0}}, s, 127, 63, wrapEdge)
+		for _, p := range [][2]int{{127, 63}, {0, 63}, {127, 0}, {0, 0}} {
+			if !d.Lit(p[0], p[1]) {
+				t.Errorf("expected pixel %v to be lit", p)
+			}
+		}
+	})
+}
+
+func TestFramebuffer(t *testing.T) {
+	t.Run("tracks the changed region", func(t *testing.T) {
+		d := NewFramebuffer(64, 32, 1)
+		d.Set(0, 3, 4, true)
+		d.Flip(0, 10, 2)
+		if want := image.Rect(3, 2, 11, 5); d.Dirty() != want {
+			t.Errorf("expected dirty region %v, got %v", want, d.Dirty())
+		}
+		d.ClearDirty()
+		d.Set(0, 3, 4, true)
+		if !d.Dirty().Empty() {
+			t.Errorf("expected setting a lit pixel to change nothing, got %v", d.Dirty())
+		}
+		d.Clear()
+		if d.Dirty() != image.Rect(0, 0, 64, 32) || d.LitCount() != 0 {
+			t.Errorf("expected clearing to blank and redraw everything")
+		}
+	})
+
+	t.Run("scrolls", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			width  int
+			scroll func(d *Framebuffer)
+			want   [2]int // where the pixel at 62, 1 ends up
+		}{
+			{name: "down", width: 64, scroll: func(d *Framebuffer) { d.ScrollDown(4) }, want: [2]int{62, 5}},
+			{name: "up", width: 64, scroll: func(d *Framebuffer) { d.ScrollUp(1) }, want: [2]int{62, 0}},
+			{name: "left", width: 64, scroll: func(d *Framebuffer) { d.ScrollLeft(4) }, want: [2]int{58, 1}},
+			{name: "right across words", width: 128, scroll: func(d *Framebuffer) { d.ScrollRight(4) }, want: [2]int{66, 1}},
+		}
+		for _, test := range cases {
+			t.Run(test.name, func(t *testing.T) {
+				d := NewFramebuffer(test.width, 32, 1)
+				d.Set(0, 62, 1, true)
+				test.scroll(&d)
+				if d.LitCount() != 1 || !d.Lit(test.want[0], test.want[1]) {
+					t.Errorf("expected the pixel at %v, got %d pixels lit", test.want, d.LitCount())
+				}
+			})
+		}
+
+		d := NewFramebuffer(64, 32, 1)
+		d.Set(0, 62, 1, true)
+		d.ScrollRight(4)
+		if d.LitCount() != 0 {
+			t.Errorf("expected pixels scrolled past the right edge to be dropped")
+		}
+	})
+
+	t.Run("golden IBM logo", func(t *testing.T) {
+		emu := getIBMEmulator(t)
+		for range 200 {
+			emu.Update()
+		}
+		if got := emu.Display.Hash(); got != 0x9865bc2da3db3b9f || emu.Display.LitCount() != 208 {
+			t.Errorf("expected the IBM logo, got hash %#x with %d pixels lit", got, emu.Display.LitCount())
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		a := getIBMEmulator(t)
+		b := getIBMEmulator(t)
+		for range 20 {
+			a.Update()
+			b.Update()
+		}
+		if a.Display.Hash() != b.Display.Hash() {
+			t.Errorf("expected the same display to hash the same")
+		}
+		b.Display.Flip(0, 0, 0)
+		if a.Display.Hash() == b.Display.Hash() {
+			t.Errorf("expected different displays to hash differently")
+		}
+	})
+}
+
+func TestLayout(t *testing.T) {
+	t.Run("eti-660 rom at 0x600", func(t *testing.T) {
+		layout := Layout{MemorySize: 4096, FontAddress: 0x50, LoadAddress: 0x600, StartPC: 0x600}
+		// LD V0, 0xA; LD F, V0
+		emu, err := NewChip8WithLayout([]byte{0x60, 0x0A, 0xF0, 0x29}, layout)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if emu.PC != 0x600 || emu.Memory[0x600] != 0x60 || emu.Memory[0x200] != 0 {
+			t.Errorf("expected the rom and PC at 0x600, PC 0x%03X", emu.PC)
+		}
+		emu.RunFrame(2)
+		if emu.Index != 0x50+5*0xA {
+			t.Errorf("expected FX29 to point at the font, got 0x%03X", emu.Index)
+		}
+	})
+
+	t.Run("font can move", func(t *testing.T) {
+		layout := DefaultLayout
+		layout.FontAddress = 0x000
+		emu, err := NewChip8WithLayout([]byte{0xF0, 0x29}, layout)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if emu.Memory[0] != 0xF0 || emu.Memory[0xF0] != 0 {
+			t.Errorf("expected the 240 byte font at 0x000")
+		}
+	})
+
+	t.Run("64k memory", func(t *testing.T) {
+		layout := DefaultLayout
+		layout.MemorySize = MaxMemorySize
+		// LD I, 0xFFF; LD V0, 0xFF; ADD I, V0
+		emu, err := NewChip8WithLayout([]byte{0xAF, 0xFF, 0x60, 0xFF, 0xF0, 0x1E}, layout)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		emu.RunFrame(3)
+		if len(emu.Memory) != 0x10000 || emu.Index != 0x10FE || emu.Registers[0xF] != 0 {
+			t.Errorf("expected Index past 4K without overflow, got 0x%04X VF %d", emu.Index, emu.Registers[0xF])
+		}
+	})
+
+	errorCases := []struct {
+		name   string
+		layout Layout
+		size   int
+		want   string
+	}{
+		{name: "rom too big", layout: DefaultLayout, size: 4096 - 0x200 + 1, want: "rom is 3585 bytes, only 3584 fit"},
+		{name: "rom past the end", layout: Layout{MemorySize: 4096, FontAddress: 0x50, LoadAddress: 0x1000, StartPC: 0x200}, size: 2, want: "only 0 fit"},
+		{name: "font overlaps rom", layout: Layout{MemorySize: 4096, FontAddress: 0x210, LoadAddress: 0x200, StartPC: 0x200}, size: 32, want: "overlaps"},
+		{name: "memory too big", layout: Layout{MemorySize: 0x20000, FontAddress: 0x50, LoadAddress: 0x200, StartPC: 0x200}, size: 2, want: "memory size"},
+		{name: "pc outside memory", layout: Layout{MemorySize: 4096, FontAddress: 0x50, LoadAddress: 0x200, StartPC: 0x1000}, size: 2, want: "program counter"},
+	}
+	for _, test := range errorCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewChip8WithLayout(make([]byte, test.size), test.layout)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+
+	t.Run("clone has its own memory", func(t *testing.T) {
+		emu := getIBMEmulator(t)
+		clone := emu.Clone()
+		clone.Memory[0x200] = 0xFF
+		if emu.Memory[0x200] == 0xFF {
+			t.Errorf("expected the clone's memory to be separate")
+		}
+	})
+}
+
+func TestFonts(t *testing.T) {
+	t.Run("built-in fonts are complete", func(t *testing.T) {
+		for _, f := range Fonts {
+			if len(f.Small) != 80 || (len(f.Big) != 0 && len(f.Big) != 100 && len(f.Big) != 160) {
+				t.Errorf("font %s has %d small and %d big bytes", f.Name, len(f.Small), len(f.Big))
+			}
+		}
+	})
+
+	t.Run("set font replaces the old one", func(t *testing.T) {
+		emu := getIBMEmulator(t)
+		vip, _ := LookupFont("vip")
+		if err := emu.SetFont(vip); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 1 is the second character, the VIP one has a wider top
+		if emu.Memory[0x55] != 0x60 || emu.Memory[0xA0] != 0 || emu.Font().Name != "vip" {
+			t.Errorf("expected the VIP font without the big font after it, got 0x%02X 0x%02X", emu.Memory[0x55], emu.Memory[0xA0])
+		}
+		big, _ := LookupFont("schip")
+		emu.SetFont(big)
+		if emu.Memory[0xA0] != 0x3C || emu.Memory[0xA0+100] != 0 {
+			t.Errorf("expected the SCHIP big digits right after the small font")
+		}
+	})
+
+	t.Run("font must fit", func(t *testing.T) {
+		layout := DefaultLayout
+		layout.FontAddress = 0x1B0
+		_, err := NewChip8WithLayout([]byte{0x00, 0xE0}, layout)
+		if err == nil || !strings.Contains(err.Error(), "overlaps") {
+			t.Errorf("expected the big font to overlap the rom, got %v", err)
+		}
+		emu := getIBMEmulator(t)
+		if err := emu.SetFont(Font{Name: "bad", Small: make([]byte, 10)}); err == nil {
+			t.Errorf("expected an error for a short font")
+		}
+	})
+
+	t.Run("parse font files", func(t *testing.T) {
+		for size, want := range map[int]bool{80: true, 180: true, 240: true, 79: false, 100: false} {
+			_, err := ParseFont("file", make([]byte, size))
+			if (err == nil) != want {
+				t.Errorf("%d bytes: expected ok %v, got %v", size, want, err)
+			}
+		}
+	})
+}
+
+func TestSeed(t *testing.T) {
+	// LD V0, random; LD V1, random; LD V2, random
+	rom := []byte{0xC0, 0xFF, 0xC1, 0xFF, 0xC2, 0xFF}
+	run := func(seed uint64) [16]uint8 {
+		emu, _ := NewChip8FromByte(rom)
+		emu.Seed(seed)
+		emu.RunFrame(3)
+		return emu.Registers
+	}
+	if run(1) != run(1) {
+		t.Errorf("expected the same seed to give the same numbers")
+	}
+	if run(1) == run(2) {
+		t.Errorf("expected different seeds to give different numbers")
+	}
+}
+
+func TestNew(t *testing.T) {
+	// LD V0, random; DRW V0, V0, 1; LD DT, V0; JP 0x206
+	rom := []byte{0xC0, 0xFF, 0xD0, 0x01, 0xF0, 0x15, 0x12, 0x06}
+
+	t.Run("options are applied", func(t *testing.T) {
+		vip, _ := LookupFont("vip")
+		layout := DefaultLayout
+		layout.LoadAddress, layout.StartPC = 0x600, 0x600
+		m, err := New(rom, WithLayout(layout), WithFont(vip), WithQuirks(Quirks{Shift: true}), WithSeed(7))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m.PC != 0x600 || m.Memory[0x600] != 0xC0 || m.Font().Name != "vip" || !m.Quirks.Shift {
+			t.Errorf("expected the options to be applied, got PC 0x%03X and font %s", m.PC, m.Font().Name)
+		}
+		other, _ := New(rom, WithLayout(layout), WithSeed(7))
+		m.RunFrame(1)
+		other.RunFrame(1)
+		if m.Registers[0] != other.Registers[0] {
+			t.Errorf("expected the same seed to give the same numbers")
+		}
+	})
+
+	t.Run("options are checked", func(t *testing.T) {
+		if _, err := New(nil); err == nil {
+			t.Errorf("expected an error for an empty rom")
+		}
+		if _, err := New(rom, WithFont(Font{Name: "bad", Small: make([]byte, 10)})); err == nil {
+			t.Errorf("expected an error for a short font")
+		}
+		if _, err := New(rom, WithLayout(Layout{MemorySize: 100})); err == nil {
+			t.Errorf("expected an error for a layout that doesn't fit")
+		}
+	})
+
+	t.Run("clock ticks the timers", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		m, _ := New(rom, WithClock(func() time.Time { return now }))
+		for range 3 {
+			m.Update()
+		}
+		m.delayTimer = 10
+		m.Update()
+		if delay, _ := m.Timers(); delay != 10 {
+			t.Errorf("expected the timers to stand still while the clock does, got %d", delay)
+		}
+		now = now.Add(time.Second / 30)
+		m.Update()
+		if delay, _ := m.Timers(); delay != 9 {
+			t.Errorf("expected one tick, got delay %d", delay)
+		}
+	})
+
+	t.Run("reset reboots without reallocating", func(t *testing.T) {
+		m, _ := New(rom, WithSeed(3), WithQuirks(Quirks{Jump: true}))
+		m.RunFrame(3)
+		first := m.Registers[0]
+		memory := &m.Memory[0]
+		m.Memory[0x300] = 0xFF
+		m.RunFrame(10)
+		m.Reset()
+
+		if &m.Memory[0] != memory {
+			t.Errorf("expected reset to keep the memory")
+		}
+		if m.PC != 0x200 || m.Cycles() != 0 || m.Memory[0x300] != 0 || m.Registers != [16]uint8{} || m.Display.LitCount() != 0 {
+			t.Errorf("expected a freshly booted machine, got PC 0x%03X after %d cycles", m.PC, m.Cycles())
+		}
+		if delay, _ := m.Timers(); delay != 0 || !m.Quirks.Jump {
+			t.Errorf("expected the timers cleared and the quirks kept")
+		}
+		if m.Display.Dirty() != image.Rect(0, 0, 64, 32) {
+			t.Errorf("expected the whole display to be redrawn, got %v", m.Display.Dirty())
+		}
+		m.RunFrame(3)
+		if m.Registers[0] != first {
+			t.Errorf("expected the run to repeat after a reset, got V0 %d, first run %d", m.Registers[0], first)
+		}
+	})
+}
+
+func TestMemoryWrap(t *testing.T) {
+	small := DefaultLayout
+	small.MemorySize = 0x800
+	big := DefaultLayout
+	big.MemorySize = MaxMemorySize
+
+	cases := []struct {
+		name   string
+		layout Layout
+		index  uint16 // set after loading, 0 to leave it to the rom
+		rom    []byte
+		check  func(emu *Chip8) bool
+	}{
+		// LD I, 0xF00; LD V0, 123; LD B, V0
+		{name: "FX33 past the end of 2K", layout: small, rom: []byte{0xAF, 0x00, 0x60, 0x7B, 0xF0, 0x33},
+			check: func(emu *Chip8) bool { return bytes.Equal(emu.Memory[0x700:0x703], []byte{1, 2, 3}) }},
+		// LD I, 0xF00; LD V0, 7; LD [I], V2; LD I, 0x700; LD V2, [I]
+		{name: "FX55 and FX65 past the end of 2K", layout: small, rom: []byte{0xAF, 0x00, 0x60, 0x07, 0xF2, 0x55, 0xAF, 0x00, 0xF2, 0x65},
+			check: func(emu *Chip8) bool { return emu.Memory[0x700] == 7 && emu.Registers[0] == 7 }},
+		// LD I, 0xF00; LD V0, 0xFF; ADD I, V0
+		{name: "FX1E past the end of 2K", layout: small, rom: []byte{0xAF, 0x00, 0x60, 0xFF, 0xF0, 0x1E},
+			check: func(emu *Chip8) bool { return emu.Index == 0x7FF && emu.Registers[0xF] == 1 }},
+		// LD V0, 123; LD B, V0
+		{name: "FX33 at the top of 64K", layout: big, index: 0xFFFE, rom: []byte{0x60, 0x7B, 0xF0, 0x33},
+			check: func(emu *Chip8) bool {
+				return emu.Memory[0xFFFE] == 1 && emu.Memory[0xFFFF] == 2 && emu.Memory[0] == 3
+			}},
+		// LD V3, 9; LD [I], V3; LD V3, [I]
+		{name: "FX55 and FX65 at the top of 64K", layout: big, index: 0xFFFE, rom: []byte{0x63, 0x09, 0xF3, 0x55, 0xF3, 0x65},
+			check: func(emu *Chip8) bool { return emu.Memory[1] == 9 && emu.Registers[3] == 9 }},
+		// LD V0, 0xFF; ADD I, V0
+		{name: "FX1E at the top of 64K", layout: big, index: 0xFFF0, rom: []byte{0x60, 0xFF, 0xF0, 0x1E},
+			check: func(emu *Chip8) bool { return emu.Index == 0xEF && emu.Registers[0xF] == 1 }},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			emu, err := NewChip8WithLayout(test.rom, test.layout)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			emu.Index = test.index
+			if err := emu.RunFrame(len(test.rom) / 2); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.check(emu) {
+				t.Errorf("unexpected state: Index 0x%04X, V0 %d, VF %d", emu.Index, emu.Registers[0], emu.Registers[0xF])
+			}
+		})
+	}
+}
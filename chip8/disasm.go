@@ -0,0 +1,101 @@
+package chip8
+
+import "fmt"
+
+// Disassemble returns the mnemonic for a single instruction, using the syntax from
+// Cowgod's Chip-8 technical reference. Unknown instructions are shown as data.
+func Disassemble(instruction uint16) string {
+	X := (uint8)(instruction & 0x0F00 >> 8)
+	Y := (uint8)(instruction & 0x00F0 >> 4)
+	N := (uint8)(instruction & 0x000F)
+	NN := (uint8)(instruction & 0x00FF)
+	NNN := (uint16)(instruction & 0x0FFF)
+
+	switch instruction & 0xF000 {
+	case 0x0000:
+		switch instruction {
+		case 0x00E0:
+			return "CLS"
+		case 0x00EE:
+			return "RET"
+		}
+	case 0x1000:
+		return fmt.Sprintf("JP 0x%03X", NNN)
+	case 0x2000:
+		return fmt.Sprintf("CALL 0x%03X", NNN)
+	case 0x3000:
+		return fmt.Sprintf("SE V%X, 0x%02X", X, NN)
+	case 0x4000:
+		return fmt.Sprintf("SNE V%X, 0x%02X", X, NN)
+	case 0x5000:
+		if N == 0 {
+			return fmt.Sprintf("SE V%X, V%X", X, Y)
+		}
+	case 0x6000:
+		return fmt.Sprintf("LD V%X, 0x%02X", X, NN)
+	case 0x7000:
+		return fmt.Sprintf("ADD V%X, 0x%02X", X, NN)
+	case 0x8000:
+		ops := map[uint8]string{0x0: "LD", 0x1: "OR", 0x2: "AND", 0x3: "XOR", 0x4: "ADD", 0x5: "SUB", 0x6: "SHR", 0x7: "SUBN", 0xE: "SHL"}
+		if op, ok := ops[N]; ok {
+			return fmt.Sprintf("%s V%X, V%X", op, X, Y)
+		}
+	case 0x9000:
+		if N == 0 {
+			return fmt.Sprintf("SNE V%X, V%X", X, Y)
+		}
+	case 0xA000:
+		return fmt.Sprintf("LD I, 0x%03X", NNN)
+	case 0xB000:
+		return fmt.Sprintf("JP V0, 0x%03X", NNN)
+	case 0xC000:
+		return fmt.Sprintf("RND V%X, 0x%02X", X, NN)
+	case 0xD000:
+		return fmt.Sprintf("DRW V%X, V%X, %d", X, Y, N)
+	case 0xE000:
+		switch NN {
+		case 0x9E:
+			return fmt.Sprintf("SKP V%X", X)
+		case 0xA1:
+			return fmt.Sprintf("SKNP V%X", X)
+		}
+	case 0xF000:
+		formats := map[uint8]string{
+			0x07: "LD V%X, DT",
+			0x0A: "LD V%X, K",
+			0x15: "LD DT, V%X",
+			0x18: "LD ST, V%X",
+			0x1E: "ADD I, V%X",
+			0x29: "LD F, V%X",
+			0x33: "LD B, V%X",
+			0x55: "LD [I], V%X",
+			0x65: "LD V%X, [I]",
+		}
+		if format, ok := formats[NN]; ok {
+			return fmt.Sprintf(format, X)
+		}
+	}
+	return fmt.Sprintf("DW 0x%04X", instruction)
+}
+
+// DisassembleROM returns one line per two byte word of the ROM, with the address
+// it is loaded at, the raw bytes and the mnemonic. A trailing odd byte is shown
+// as data.
+func DisassembleROM(rom []byte) []string {
+	return DisassembleROMAt(rom, DefaultLayout.LoadAddress)
+}
+
+// DisassembleROMAt is DisassembleROM for a ROM loaded at address
+func DisassembleROMAt(rom []byte, start uint16) []string {
+	var lines []string
+	for i := 0; i < len(rom); i += 2 {
+		address := (int)(start) + i
+		if i+1 == len(rom) {
+			lines = append(lines, fmt.Sprintf("0x%03X  %02X    DB 0x%02X", address, rom[i], rom[i]))
+			break
+		}
+		instruction := uint16FromTwoBytes(rom[i], rom[i+1])
+		lines = append(lines, fmt.Sprintf("0x%03X  %04X  %s", address, instruction, Disassemble(instruction)))
+	}
+	return lines
+}
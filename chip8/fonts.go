@@ -0,0 +1,201 @@
+package chip8
+
+import "fmt"
+
+// Font is the hex font FX29 points into. Small has 16 characters of 5 bytes (4x5
+// pixels in the high nibble). Big is the SCHIP 8x10 font, 10 bytes per character,
+// for the digits or all 16 characters; it is loaded right after Small.
+type Font struct {
+	Name  string `json:"name"`
+	Small []byte `json:"small"`
+	Big   []byte `json:"big,omitempty"`
+}
+
+// size returns the bytes of memory the font takes
+func (f Font) size() int {
+	return len(f.Small) + len(f.Big)
+}
+
+// check returns an error if the small or the big font is not one of the supported sizes
+func (f Font) check() error {
+	if len(f.Small) != smallFontSize {
+		return fmt.Errorf("font %q has a %d byte small font, expected %d", f.Name, len(f.Small), smallFontSize)
+	}
+	if n := len(f.Big); n != 0 && n != bigDigitsSize && n != bigCharacterSize {
+		return fmt.Errorf("font %q has a %d byte big font, expected %d or %d", f.Name, n, bigDigitsSize, bigCharacterSize)
+	}
+	return nil
+}
+
+// Font sizes
+const (
+	smallFontSize    = 16 * 5
+	bigDigitsSize    = 10 * 10
+	bigCharacterSize = 16 * 10
+)
+
+// smallFont is the font most interpreters since CHIP-48 use, also the SCHIP and Octo one
+var smallFont = []byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// Fonts are the built-in fonts, the first is the default
+var Fonts = []Font{
+	{
+		Name:  "octo",
+		Small: smallFont,
+		Big: []byte{
+			0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+			0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+			0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+			0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+			0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+			0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+			0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+			0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+			0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+			0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+			0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+			0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+			0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+			0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+			0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+			0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+		},
+	},
+	{
+		Name:  "schip",
+		Small: smallFont,
+		Big: []byte{
+			0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+			0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+			0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+			0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+			0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+			0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+			0x3E, 0x7C, 0xE0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+			0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+			0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+			0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+		},
+	},
+	{
+		Name: "vip",
+		Small: []byte{
+			0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+			0x60, 0x20, 0x20, 0x20, 0x70, // 1
+			0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+			0xF0, 0x10, 0x70, 0x10, 0xF0, // 3
+			0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+			0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+			0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+			0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+			0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+			0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+			0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+			0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+			0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+			0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+			0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+			0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+		},
+	},
+	{
+		Name: "dream6800",
+		Small: []byte{
+			0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+			0x40, 0x40, 0x40, 0x40, 0x40, // 1
+			0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+			0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+			0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+			0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+			0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+			0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+			0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+			0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+			0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+			0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+			0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+			0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+			0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+			0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+		},
+	},
+	{
+		Name: "eti660",
+		Small: []byte{
+			0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+			0x20, 0x20, 0x20, 0x20, 0x20, // 1
+			0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+			0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+			0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+			0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+			0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+			0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+			0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+			0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+			0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+			0x80, 0x80, 0xE0, 0xA0, 0xE0, // B
+			0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+			0x20, 0x20, 0xE0, 0xA0, 0xE0, // D
+			0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+			0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+		},
+	},
+}
+
+// DefaultFont returns the font used when none is chosen
+func DefaultFont() Font {
+	return Fonts[0]
+}
+
+// LookupFont returns the built-in font with the given name
+func LookupFont(name string) (Font, bool) {
+	for _, f := range Fonts {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Font{}, false
+}
+
+// FontNames returns the names of the built-in fonts
+func FontNames() []string {
+	names := make([]string, len(Fonts))
+	for i, f := range Fonts {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// ParseFont reads a font file: the 80 bytes of the small font, optionally followed
+// by a big font of 100 bytes (the digits) or 160 bytes (all 16 characters)
+func ParseFont(name string, data []byte) (Font, error) {
+	if len(data) < smallFontSize {
+		return Font{}, fmt.Errorf("font is %d bytes, expected at least %d", len(data), smallFontSize)
+	}
+	big := data[smallFontSize:]
+	switch len(big) {
+	case 0:
+		big = nil
+	case bigDigitsSize, bigCharacterSize:
+	default:
+		return Font{}, fmt.Errorf("font is %d bytes, expected %d, %d or %d", len(data), smallFontSize, smallFontSize+bigDigitsSize, smallFontSize+bigCharacterSize)
+	}
+	return Font{Name: name, Small: append([]byte(nil), data[:smallFontSize]...), Big: append([]byte(nil), big...)}, nil
+}
@@ -0,0 +1,297 @@
+package chip8
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"math/bits"
+)
+
+// Limits of a Framebuffer: the SCHIP and XO-CHIP high resolution display with up to
+// four bit planes
+const (
+	MaxWidth  = 128
+	MaxHeight = 64
+	MaxPlanes = 4
+)
+
+// rowWords is the number of uint64s that hold a row of MaxWidth pixels
+const rowWords = MaxWidth / 64
+
+// Framebuffer is a packed display. Each row of each plane is stored as uint64s with
+// the leftmost pixel in the most significant bit, the same order as sprite data.
+// It is a value, so a copy of a framebuffer (or of the emulator made with Clone) is
+// independent of the original.
+//
+// The framebuffer keeps the region changed since ClearDirty, so frontends only
+// have to redraw that.
+type Framebuffer struct {
+	width, height, planes int
+	bits                  [MaxPlanes * MaxHeight * rowWords]uint64
+	dirty                 image.Rectangle
+}
+
+// NewFramebuffer returns a blank width by height display with the given number of
+// planes. It panics if the size is beyond MaxWidth, MaxHeight or MaxPlanes.
+func NewFramebuffer(width, height, planes int) Framebuffer {
+	if width <= 0 || width > MaxWidth || height <= 0 || height > MaxHeight || planes <= 0 || planes > MaxPlanes {
+		panic(fmt.Sprintf("framebuffer size %dx%d with %d planes is not supported", width, height, planes))
+	}
+	return Framebuffer{width: width, height: height, planes: planes}
+}
+
+func (f *Framebuffer) Width() int {
+	return f.width
+}
+
+func (f *Framebuffer) Height() int {
+	return f.height
+}
+
+func (f *Framebuffer) Planes() int {
+	return f.planes
+}
+
+// Row returns the packed pixels of row y of a plane. Only the first width bits
+// are used.
+func (f *Framebuffer) Row(plane, y int) []uint64 {
+	i := (plane*MaxHeight + y) * rowWords
+	return f.bits[i : i+rowWords]
+}
+
+// Pixel returns the planes lit at x, y, bit n set for plane n
+func (f *Framebuffer) Pixel(x, y int) uint8 {
+	var p uint8
+	for plane := range f.planes {
+		if f.Row(plane, y)[x/64]&mask(x) != 0 {
+			p |= 1 << plane
+		}
+	}
+	return p
+}
+
+// Lit reports whether the pixel at x, y is lit in any plane
+func (f *Framebuffer) Lit(x, y int) bool {
+	return f.Pixel(x, y) != 0
+}
+
+// Set turns the pixel at x, y of a plane on or off
+func (f *Framebuffer) Set(plane, x, y int, on bool) {
+	row := f.Row(plane, y)
+	if (row[x/64]&mask(x) != 0) == on {
+		return
+	}
+	row[x/64] ^= mask(x)
+	f.markDirty(image.Rect(x, y, x+1, y+1))
+}
+
+// Flip toggles the pixel at x, y of a plane and reports whether it was on
+func (f *Framebuffer) Flip(plane, x, y int) bool {
+	row := f.Row(plane, y)
+	on := row[x/64]&mask(x) != 0
+	row[x/64] ^= mask(x)
+	f.markDirty(image.Rect(x, y, x+1, y+1))
+	return on
+}
+
+// Clear turns every pixel of every plane off
+func (f *Framebuffer) Clear() {
+	if f.bits != [len(f.bits)]uint64{} {
+		f.bits = [len(f.bits)]uint64{}
+		f.markDirty(f.bounds())
+	}
+}
+
+// ScrollDown moves every plane down n pixels, blanking the rows at the top
+func (f *Framebuffer) ScrollDown(n int) {
+	f.scrollRows(n)
+}
+
+// ScrollUp moves every plane up n pixels, blanking the rows at the bottom
+func (f *Framebuffer) ScrollUp(n int) {
+	f.scrollRows(-n)
+}
+
+// ScrollRight moves every plane right n pixels, blanking the columns on the left
+func (f *Framebuffer) ScrollRight(n int) {
+	f.scrollColumns(n)
+}
+
+// ScrollLeft moves every plane left n pixels, blanking the columns on the right
+func (f *Framebuffer) ScrollLeft(n int) {
+	f.scrollColumns(-n)
+}
+
+// scrollRows moves the rows down by n, or up if n is negative
+func (f *Framebuffer) scrollRows(n int) {
+	if n == 0 {
+		return
+	}
+	for plane := range f.planes {
+		if n > 0 {
+			for y := f.height - 1; y >= 0; y-- {
+				f.copyRow(plane, y, y-n)
+			}
+		} else {
+			for y := range f.height {
+				f.copyRow(plane, y, y-n)
+			}
+		}
+	}
+	f.markDirty(f.bounds())
+}
+
+// copyRow sets row y of a plane to row from, or blanks it if from is off the display
+func (f *Framebuffer) copyRow(plane, y, from int) {
+	if from < 0 || from >= f.height {
+		clear(f.Row(plane, y))
+		return
+	}
+	copy(f.Row(plane, y), f.Row(plane, from))
+}
+
+// scrollColumns moves every row right by n, or left if n is negative
+func (f *Framebuffer) scrollColumns(n int) {
+	if n == 0 {
+		return
+	}
+	for plane := range f.planes {
+		for y := range f.height {
+			row := f.Row(plane, y)
+			if n > 0 {
+				shiftRight(row, n)
+			} else {
+				shiftLeft(row, -n)
+			}
+			f.trim(row)
+		}
+	}
+	f.markDirty(f.bounds())
+}
+
+// trim drops the pixels of row past the width of the display
+func (f *Framebuffer) trim(row []uint64) {
+	used := (f.width + 63) / 64
+	clear(row[used:])
+	if f.width%64 != 0 {
+		row[used-1] &^= ^uint64(0) >> (f.width % 64)
+	}
+}
+
+// shiftRight moves the bits of row n places towards the end of the row
+func shiftRight(row []uint64, n int) {
+	words, n := n/64, n%64
+	for i := len(row) - 1; i >= 0; i-- {
+		var w uint64
+		if j := i - words; j >= 0 {
+			w = row[j] >> n
+			if n > 0 && j > 0 {
+				w |= row[j-1] << (64 - n)
+			}
+		}
+		row[i] = w
+	}
+}
+
+// shiftLeft moves the bits of row n places towards the start of the row
+func shiftLeft(row []uint64, n int) {
+	words, n := n/64, n%64
+	for i := range row {
+		var w uint64
+		if j := i + words; j < len(row) {
+			w = row[j] << n
+			if n > 0 && j+1 < len(row) {
+				w |= row[j+1] >> (64 - n)
+			}
+		}
+		row[i] = w
+	}
+}
+
+// Dirty returns the region changed since the last ClearDirty, empty if nothing was
+func (f *Framebuffer) Dirty() image.Rectangle {
+	return f.dirty
+}
+
+// ClearDirty marks the display as drawn
+func (f *Framebuffer) ClearDirty() {
+	f.dirty = image.Rectangle{}
+}
+
+func (f *Framebuffer) markDirty(r image.Rectangle) {
+	f.dirty = f.dirty.Union(r)
+}
+
+func (f *Framebuffer) bounds() image.Rectangle {
+	return image.Rect(0, 0, f.width, f.height)
+}
+
+// Hash returns an FNV-1a hash of the size and the pixels, for comparing displays
+// in tests without storing them
+func (f *Framebuffer) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(v uint64) {
+		for i := range buf {
+			buf[i] = byte(v >> (8 * i))
+		}
+		h.Write(buf[:])
+	}
+	write(uint64(f.width))
+	write(uint64(f.height))
+	write(uint64(f.planes))
+	for plane := range f.planes {
+		for y := range f.height {
+			for _, w := range f.Row(plane, y) {
+				write(w)
+			}
+		}
+	}
+	return h.Sum64()
+}
+
+// LitCount returns the number of pixels lit in any plane
+func (f *Framebuffer) LitCount() int {
+	n := 0
+	for y := range f.height {
+		for i := range rowWords {
+			var w uint64
+			for plane := range f.planes {
+				w |= f.Row(plane, y)[i]
+			}
+			n += bits.OnesCount64(w)
+		}
+	}
+	return n
+}
+
+// Matrix returns the top left 64x32 pixels as the column-major [64][32]bool the
+// display used to be, for code written against that
+func (f *Framebuffer) Matrix() [64][32]bool {
+	var m [64][32]bool
+	for x := range min(f.width, 64) {
+		for y := range min(f.height, 32) {
+			m[x][y] = f.Lit(x, y)
+		}
+	}
+	return m
+}
+
+// mask returns the bit of pixel x in its word
+func mask(x int) uint64 {
+	return 1 << (63 - x%64)
+}
+
+// framePlane is a plane of a Framebuffer for blit
+type framePlane struct {
+	f     *Framebuffer
+	plane int
+}
+
+func (p framePlane) size() (int, int) {
+	return p.f.width, p.f.height
+}
+
+func (p framePlane) flip(x, y int) bool {
+	return p.f.Flip(p.plane, x, y)
+}
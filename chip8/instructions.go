@@ -2,14 +2,11 @@ package chip8
 
 import (
 	"fmt"
-	"math/rand"
-	"slices"
 )
 
 // op00E0 clears the screen
 func (c *Chip8) op00E0() {
-	var blankDisplay [64][32]bool
-	c.Display = blankDisplay
+	c.Display.Clear()
 	c.DebugMsg = "Op00E0: clear screen"
 }
 
@@ -30,15 +27,16 @@ func (c *Chip8) op1NNN(location uint16) {
 	c.DebugMsg = fmt.Sprintf("Op1NNN: set PC to NNN (0x%04X)", c.PC)
 }
 
-// op2NNN adds NNN to the stack
-func (c *Chip8) op2NNN(address uint16) {
+// op2NNN adds NNN to the stack. It returns an error if the stack is full.
+func (c *Chip8) op2NNN(address uint16) error {
 	if c.stackPointer == len(c.Stack) {
-		panic("stack overflow!")
+		return fmt.Errorf("stack overflow at 0x%03X: more than %d nested calls", address-2, len(c.Stack))
 	}
 
 	c.Stack[c.stackPointer] = address
 	c.stackPointer += 1
 	c.DebugMsg = fmt.Sprintf("Op2NNN: push NNN (0x%04X) to stack", address)
+	return nil
 }
 
 // op3XNN skips one instruction if register X is equal to NN (adds 2 to Program Counter)
@@ -83,21 +81,30 @@ func (c *Chip8) op8XY0(x uint8, y uint8) {
 	c.DebugMsg = fmt.Sprintf("Op8XY0: set V%X to V%X, (result: %d)", x, y, c.Registers[x])
 }
 
-// op8XY1 sets VX to BITWISE OR of VX and VY
+// op8XY1 sets VX to BITWISE OR of VX and VY. With the logic quirk VF is reset to 0.
 func (c *Chip8) op8XY1(x uint8, y uint8) {
 	c.Registers[x] = c.Registers[x] | c.Registers[y]
+	if c.Quirks.Logic {
+		c.Registers[0xF] = 0
+	}
 	c.DebugMsg = fmt.Sprintf("Op8XY1: set V%X to bitwise OR of V%X and V%X (result: %d)", x, x, y, c.Registers[x])
 }
 
-// op8XY2 sets VX to BITWISE AND of VX and VY
+// op8XY2 sets VX to BITWISE AND of VX and VY. With the logic quirk VF is reset to 0.
 func (c *Chip8) op8XY2(x uint8, y uint8) {
 	c.Registers[x] = c.Registers[x] & c.Registers[y]
+	if c.Quirks.Logic {
+		c.Registers[0xF] = 0
+	}
 	c.DebugMsg = fmt.Sprintf("Op8XY2: set V%X to bitwise AND of V%X and V%X (result: %d)", x, x, y, c.Registers[x])
 }
 
-// op8XY3 sets VX to XOR of VX and VY
+// op8XY3 sets VX to XOR of VX and VY. With the logic quirk VF is reset to 0.
 func (c *Chip8) op8XY3(x uint8, y uint8) {
 	c.Registers[x] = c.Registers[x] ^ c.Registers[y]
+	if c.Quirks.Logic {
+		c.Registers[0xF] = 0
+	}
 	c.DebugMsg = fmt.Sprintf("Op8XY3: set V%X to bitwise XOR of V%X and V%X (result: %d)", x, x, y, c.Registers[x])
 }
 
@@ -129,9 +136,11 @@ func (c *Chip8) op8XY5(x uint8, y uint8) {
 
 // op08XY6 shifts VY one bit to the right and stores in VX. VF is set to the bit that
 // shifted out.
-// NOTE: Super-CHIP 8 has different behavior that will need to be implemented; it shifts
-// VX in place and ignores Y.
+// With the shift quirk (CHIP-48 and Super-CHIP) it shifts VX in place and ignores Y.
 func (c *Chip8) op8XY6(x uint8, y uint8) {
+	if c.Quirks.Shift {
+		y = x
+	}
 	r_x := c.Registers[y]
 	r_f := 0x01 & r_x
 	c.Registers[x] = r_x >> 1
@@ -155,9 +164,11 @@ func (c *Chip8) op8XY7(x uint8, y uint8) {
 
 // op08XYE shifts VY one bit to the left and stores in VX. VF is set to the bit that
 // shifted out.
-// NOTE: Super-CHIP 8 has different behavior that will need to be implemented; it shifts
-// VX in place and ignores Y.
+// With the shift quirk (CHIP-48 and Super-CHIP) it shifts VX in place and ignores Y.
 func (c *Chip8) op8XYE(x uint8, y uint8) {
+	if c.Quirks.Shift {
+		y = x
+	}
 	r_x := c.Registers[y]
 	r_f := r_x >> 7 & 0x1
 	c.Registers[x] = r_x << 1
@@ -180,16 +191,20 @@ func (c *Chip8) opANNN(value uint16) {
 }
 
 // opBNNN sets the program counter to NNN plus value in V0
-// Note: Super Chip8 sets the program counter to VX plus NN instead (unclear on exact behavior)
+// With the jump quirk (CHIP-48 and Super-CHIP) this is BXNN and jumps to XNN plus VX
 func (c *Chip8) opBNNN(value uint16) {
-	r_0 := c.Registers[0]
-	c.PC = value + uint16(r_0)
-	c.DebugMsg = fmt.Sprintf("OpBNNN: set program counter to V0 (0x%02X) + 0x%03X: 0x%04X", r_0, value, c.PC)
+	x := uint8(0)
+	if c.Quirks.Jump {
+		x = (uint8)(value >> 8)
+	}
+	r_x := c.Registers[x]
+	c.PC = value + uint16(r_x)
+	c.DebugMsg = fmt.Sprintf("OpBNNN: set program counter to V%X (0x%02X) + 0x%03X: 0x%04X", x, r_x, value, c.PC)
 }
 
 // opCXNN generates a random number, ands it with NN, and stores in X
 func (c *Chip8) opCXNN(x uint8, value uint8) {
-	r := (uint8)(rand.Intn(0xFF))
+	r := (uint8)(c.rng.Uint64())
 	result := r & value
 	c.Registers[x] = result
 	c.DebugMsg = fmt.Sprintf("OpCXNN: AND random number (%d) to NN (%d) = %d and store in V%X", r, value, result, x)
@@ -197,47 +212,39 @@ func (c *Chip8) opCXNN(x uint8, value uint8) {
 
 // opDXYN draws an N pixel tall sprite from the value at Index
 // drawing is done at coordinates XY. If any pixels are turned off
-// VF is set to 1.
+// VF is set to 1. With the DisplayWait quirk it first waits for the
+// start of the next frame (reduces program counter by 2 until then).
 func (c *Chip8) opDXYN(x_register uint8, y_register uint8, N uint8) {
-	// Initial position can wrap around the screen, but actual drawing
-	// will not
-	x := c.Registers[x_register] % 64
-	y := c.Registers[y_register] % 32
-	c.Registers[0xF] = 0
+	if c.Quirks.DisplayWait && !c.vblank {
+		c.drawWait = true
+		c.PC -= 2
+		c.DebugMsg = "OpDXYN: waiting for the next frame to draw"
+		return
+	}
+	c.drawWait, c.vblank = false, false
 
-	for i := range N {
-		sprite := c.Memory[c.Index+(uint16)(i)] // Get sprite data for this row
-
-		// For each of 8 bits
-		for s := range 8 {
-			var x_pos uint8 = x + (uint8)(s)
-			if x_pos >= 64 {
-				continue
-			}
-			var bit uint8 = 0x80 & sprite // Get most significant bit
-			sprite = sprite << 1          // Shift 1 left
-
-			// Any bit that is on will flip the current pixel. Anything turned off sets
-			// register F to 1
-			if c.Display[x_pos][y] && bit == 128 {
-				c.Registers[0xF] = 1
-				c.Display[x_pos][y] = false
-			} else if !c.Display[x_pos][y] && bit == 128 {
-				c.Display[x_pos][y] = true
-			}
-		}
-		y += 1
-		// Stop drawing if reached bottom of screen
-		if y >= 32 {
-			break
-		}
+	var data [15]byte
+	for i := range (int)(N) {
+		data[i] = c.Memory[c.address(i)]
+	}
+	e := clipEdge
+	if c.Quirks.Wrap {
+		e = wrapEdge
+	}
+	x := c.Registers[x_register]
+	y := c.Registers[y_register]
+	hit := blit([]plane{framePlane{&c.Display, 0}}, sprite{data: data[:N], width: 8, height: (int)(N)}, (int)(x), (int)(y), e)
+
+	c.Registers[0xF] = 0
+	if hit.rows > 0 {
+		c.Registers[0xF] = 1
 	}
 	c.DebugMsg = fmt.Sprintf("OpDXYN: draw %d pixel tall sprint starting at (%d, %d)", N, x, y)
 }
 
 // opEX9E skips one instruction if key stored in X is pressed
 func (c *Chip8) opEX9E(x uint8) {
-	if slices.Contains(c.keysPressed, c.Registers[x]) {
+	if c.keys[c.Registers[x]&0x0F] {
 		c.PC = c.PC + 2
 	}
 	c.DebugMsg = fmt.Sprintf("OpEXA1: skip next instruction if key stored in V%X (%X) is pressed", x, c.Registers[x])
@@ -245,7 +252,7 @@ func (c *Chip8) opEX9E(x uint8) {
 
 // opEXA1 skips one instruction if key stored in X is not pressed
 func (c *Chip8) opEXA1(x uint8) {
-	if !slices.Contains(c.keysPressed, c.Registers[x]) {
+	if !c.keys[c.Registers[x]&0x0F] {
 		c.PC = c.PC + 2
 	}
 	c.DebugMsg = fmt.Sprintf("OpEXA1: skip next instruction if key V%X (%X) is not pressed", x, c.Registers[x])
@@ -269,28 +276,36 @@ func (c *Chip8) opFX18(x uint8) {
 	c.DebugMsg = fmt.Sprintf("OpFX18: set soundTimer to value of V%X: %d", x, c.soundTimer)
 }
 
-// opFX1E adds the value of X to the index register. If it overflows from
-// 0FFF to 1000 it should set VF to 1, this is not standard behavior but is safe
+// opFX1E adds the value of X to the index register. If it overflows past the end
+// of memory (0FFF to 1000 with 4K) it should set VF to 1, this is not standard
+// behavior but is safe. Index wraps to the size of memory.
 func (c *Chip8) opFX1E(x uint8) {
-	new_i := c.Index + (uint16)(c.Registers[x])
-	if new_i >= 0x1000 {
+	new_i := (int)(c.Index) + (int)(c.Registers[x])
+	if new_i >= len(c.Memory) {
 		c.Registers[0xF] = 1
-		new_i -= 0x1000
+		new_i %= len(c.Memory)
 	}
 	c.DebugMsg = fmt.Sprintf("OpFX1E: add V%X to Index 0x%03X, new value: 0x%03X; Overflow 0x%X", x, c.Index, new_i, c.Registers[0xF])
-	c.Index = new_i
+	c.Index = (uint16)(new_i)
 }
 
-// opFX0A blocks until a key is pressed (reduces program counter by 2)
-// The key pressed is stored in register X. If multiple keys are pressed
-// it stores the first one the frontend provided in the slice.
+// opFX0A blocks until a key is pressed and released (reduces program counter by 2)
+// The key is stored in register X. Keys that are already held when the wait starts
+// don't count until they are released and pressed again, so a held key doesn't
+// answer several FX0As in a row. With the KeyPress quirk it finishes as soon as a
+// key is pressed.
 func (c *Chip8) opFX0A(x uint8) {
-	if len(c.keysPressed) == 0 {
+	if !c.keyWait.active {
+		c.keyWait = keyWait{active: true, key: -1, stale: c.keys}
+	}
+
+	if !c.keyWait.done {
 		c.PC -= 2
 		c.DebugMsg = fmt.Sprintf("OpFX0A: waiting for keypress to store in register 0x%X", x)
 		return
 	}
-	key := c.keysPressed[0]
+	key := (uint8)(c.keyWait.key)
+	c.keyWait = keyWait{key: -1}
 	c.Registers[x] = key
 	c.DebugMsg = fmt.Sprintf("OpFX0A: Key 0x%X stored in register 0x%X", key, x)
 }
@@ -299,7 +314,7 @@ func (c *Chip8) opFX0A(x uint8) {
 // from font table)
 func (c *Chip8) opFX29(x uint8) {
 	font_char := c.Registers[x] & 0x0F
-	var loc uint16 = 0x0050 + (5 * (uint16)(font_char))
+	var loc uint16 = c.layout.FontAddress + (5 * (uint16)(font_char))
 	c.Index = loc
 	c.DebugMsg = fmt.Sprintf("OpFX29: setting index to location of font char 0x%X, i = 0x%04X", font_char, c.Index)
 }
@@ -311,20 +326,21 @@ func (c *Chip8) opFX33(x uint8) {
 	d1 := (val / 100) % 10
 	d2 := (val / 10) % 10
 	d3 := val % 10
-	c.Memory[c.Index] = d1
-	c.Memory[c.Index+1] = d2
-	c.Memory[c.Index+2] = d3
+	c.Memory[c.address(0)] = d1
+	c.Memory[c.address(1)] = d2
+	c.Memory[c.address(2)] = d3
 	c.DebugMsg = fmt.Sprintf("OpFX33: storing each digit of %d (Register V%X) into memory starting at index 0x%04X", c.Registers[x], x, c.Index)
 }
 
 // opFX55 stores each variable register between 0 and X and stores starting at
-// index I. Modern behavior implemented, I is not changed.
+// index I. By default I is not changed, see incrementIndex for the memory quirks.
 func (c *Chip8) opFX55(x uint8) {
 	i := c.Index
 	for j := range x + 1 {
-		c.Memory[i+(uint16)(j)] = c.Registers[j]
+		c.Memory[c.address((int)(j))] = c.Registers[j]
 	}
-	c.DebugMsg = fmt.Sprintf("OpFX55: storing each register up to %X into memory starting at 0x%04X", x, c.Index)
+	c.DebugMsg = fmt.Sprintf("OpFX55: storing each register up to %X into memory starting at 0x%04X", x, i)
+	c.incrementIndex(x)
 }
 
 // opFX65 takes values starting at index I and loads into each register up between
@@ -332,7 +348,27 @@ func (c *Chip8) opFX55(x uint8) {
 func (c *Chip8) opFX65(x uint8) {
 	i := c.Index
 	for j := range x + 1 {
-		c.Registers[j] = c.Memory[i+(uint16)(j)]
+		c.Registers[j] = c.Memory[c.address((int)(j))]
+	}
+	c.DebugMsg = fmt.Sprintf("OpFX65: load bytes from memory starting at location 0x%04X into registers up to %X", i, x)
+	c.incrementIndex(x)
+}
+
+// address returns the memory address offset bytes past Index. Addresses wrap at
+// the end of memory, so an Index past it (ANNN can set 0xFFF with 2K of memory)
+// or near the top can't read or write outside it.
+func (c *Chip8) address(offset int) int {
+	return ((int)(c.Index) + offset) % len(c.Memory)
+}
+
+// incrementIndex moves Index past the registers stored or loaded by FX55 and FX65
+// when one of the memory quirks is enabled. Index wraps at the end of memory.
+func (c *Chip8) incrementIndex(x uint8) {
+	step := 0
+	if c.Quirks.MemoryIncrement {
+		step = (int)(x) + 1
+	} else if c.Quirks.MemoryIncrementByX {
+		step = (int)(x)
 	}
-	c.DebugMsg = fmt.Sprintf("OpFX65: load bytes from memory starting at location 0x%04X into registers up to %X", c.Index, x)
+	c.Index = (uint16)(((int)(c.Index) + step) % len(c.Memory))
 }
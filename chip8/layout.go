@@ -0,0 +1,40 @@
+package chip8
+
+import "fmt"
+
+// Layout is where the interpreter puts things in memory
+type Layout struct {
+	MemorySize  int    `json:"memorySize"`  // bytes of memory, 4096 on most platforms and 65536 for XO-CHIP
+	FontAddress uint16 `json:"fontAddress"` // where the hex font is loaded, FX29 points into it
+	LoadAddress uint16 `json:"loadAddress"` // where the ROM is loaded, 0x600 for ETI-660 ROMs
+	StartPC     uint16 `json:"startPC"`     // the program counter when the machine starts
+}
+
+// DefaultLayout is the COSMAC VIP layout most ROMs are written for
+var DefaultLayout = Layout{MemorySize: 4096, FontAddress: 0x50, LoadAddress: 0x200, StartPC: 0x200}
+
+// MaxMemorySize is the most memory 16 bit addresses reach
+const MaxMemorySize = 0x10000
+
+// validate checks that a font of fontSize bytes and a ROM of romSize bytes fit in
+// memory without overlapping and that the program starts inside memory
+func (l Layout) validate(romSize, fontSize int) error {
+	if l.MemorySize < 0x200 || l.MemorySize > MaxMemorySize {
+		return fmt.Errorf("memory size must be between 512 and %d bytes, got %d", MaxMemorySize, l.MemorySize)
+	}
+	font_start, font_end := (int)(l.FontAddress), (int)(l.FontAddress)+fontSize
+	if font_end > l.MemorySize {
+		return fmt.Errorf("font at 0x%03X does not fit in %d bytes of memory", l.FontAddress, l.MemorySize)
+	}
+	rom_start, rom_end := (int)(l.LoadAddress), (int)(l.LoadAddress)+romSize
+	if rom_end > l.MemorySize {
+		return fmt.Errorf("rom is %d bytes, only %d fit between 0x%03X and the end of memory", romSize, max(l.MemorySize-rom_start, 0), l.LoadAddress)
+	}
+	if font_start < rom_end && rom_start < font_end {
+		return fmt.Errorf("font at 0x%03X overlaps the rom at 0x%03X", l.FontAddress, l.LoadAddress)
+	}
+	if (int)(l.StartPC)+2 > l.MemorySize {
+		return fmt.Errorf("program counter 0x%03X is outside %d bytes of memory", l.StartPC, l.MemorySize)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package chip8
+
+import (
+	"fmt"
+	"time"
+)
+
+// Machine is the emulator as New returns it. It is the same type as Chip8.
+type Machine = Chip8
+
+// Option changes how New sets up a machine
+type Option func(*options)
+
+// options are the settings New builds a machine from
+type options struct {
+	layout Layout
+	font   Font
+	quirks Quirks
+	seed   uint64
+	now    func() time.Time
+}
+
+// WithLayout places the font and the ROM and sizes memory as layout says
+func WithLayout(layout Layout) Option {
+	return func(o *options) {
+		o.layout = layout
+	}
+}
+
+// WithFont loads f instead of the default font
+func WithFont(f Font) Option {
+	return func(o *options) {
+		o.font = f
+	}
+}
+
+// WithQuirks sets the interpreter behaviour, see Quirks
+func WithQuirks(q Quirks) Option {
+	return func(o *options) {
+		o.quirks = q
+	}
+}
+
+// WithSeed seeds the random number generator, see Seed. Without it the seed comes
+// from the host clock.
+func WithSeed(seed uint64) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// WithClock replaces the host clock Update uses to tick the timers. RunFrame does
+// not look at the clock.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// New returns a machine with rom loaded and the options applied in order. It
+// returns an error if the ROM is empty or it and the font don't fit the layout.
+func New(rom []byte, opts ...Option) (*Machine, error) {
+	o := options{
+		layout: DefaultLayout,
+		font:   DefaultFont(),
+		seed:   uint64(time.Now().UnixNano()),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(rom) == 0 {
+		return nil, fmt.Errorf("no rom data provided")
+	}
+	if err := o.font.check(); err != nil {
+		return nil, err
+	}
+	if err := o.layout.validate(len(rom), o.font.size()); err != nil {
+		return nil, err
+	}
+
+	m := &Machine{
+		Memory:       make([]byte, o.layout.MemorySize),
+		layout:       o.layout,
+		rom:          append([]byte(nil), rom...),
+		font:         o.font,
+		tickDuration: time.Second / 60,
+		now:          o.now,
+		Quirks:       o.quirks,
+	}
+	m.seed = o.seed
+	m.Reset()
+	return m, nil
+}
+
+// Reset reboots the machine: memory holds only the ROM and the font again and the
+// registers, timers, keys and display are cleared. The random number generator
+// restarts from the last seed, so a reset replays like a new machine. The quirks,
+// the font and the memory are kept, nothing is allocated.
+func (c *Chip8) Reset() {
+	clear(c.Memory)
+	copy(c.Memory[c.layout.LoadAddress:], c.rom)
+	c.loadFonts()
+
+	c.PC = c.layout.StartPC
+	c.Index = 0
+	c.Stack = [16]uint16{}
+	c.stackPointer = 0
+	c.Registers = [16]uint8{}
+	c.delayTimer, c.soundTimer = 0, 0
+	c.timeStart = time.Time{}
+	c.keys = [16]bool{}
+	c.keyWait = keyWait{key: -1}
+	c.drawWait, c.vblank = false, false
+	c.cycles = 0
+	c.DebugMsg = ""
+	c.Seed(c.seed)
+
+	// The whole display is dirty, so frontends redraw it even if it was blank
+	c.Display = NewFramebuffer(64, 32, 1)
+	c.Display.markDirty(c.Display.bounds())
+}
@@ -0,0 +1,42 @@
+package chip8
+
+import "fmt"
+
+// Quirks toggles behaviour that differs between CHIP-8 interpreters. The zero value
+// is the "modern" behaviour. The JSON names match the ones used by the community
+// chip-8-database where it has them.
+type Quirks struct {
+	Shift              bool `json:"shift"`              // 8XY6 and 8XYE shift VX in place and ignore VY
+	MemoryIncrement    bool `json:"memoryIncrement"`    // FX55 and FX65 leave Index at I + X + 1 (COSMAC VIP)
+	MemoryIncrementByX bool `json:"memoryIncrementByX"` // FX55 and FX65 leave Index at I + X (CHIP-48, SCHIP 1.0)
+	Jump               bool `json:"jump"`               // BXNN jumps to XNN plus VX instead of NNN plus V0
+	Logic              bool `json:"logic"`              // 8XY1, 8XY2 and 8XY3 reset VF to 0
+	KeyPress           bool `json:"keyPress"`           // FX0A finishes when a key is pressed instead of released
+	DisplayWait        bool `json:"vblank"`             // DXYN waits for the next frame, so at most one sprite is drawn per frame (COSMAC VIP)
+	Wrap               bool `json:"wrap"`               // DXYN wraps sprites around the edges of the screen instead of clipping them
+}
+
+// Set turns the quirk with the given JSON name on or off
+func (q *Quirks) Set(name string, on bool) error {
+	switch name {
+	case "shift":
+		q.Shift = on
+	case "memoryIncrement":
+		q.MemoryIncrement = on
+	case "memoryIncrementByX":
+		q.MemoryIncrementByX = on
+	case "jump":
+		q.Jump = on
+	case "logic":
+		q.Logic = on
+	case "keyPress":
+		q.KeyPress = on
+	case "vblank":
+		q.DisplayWait = on
+	case "wrap":
+		q.Wrap = on
+	default:
+		return fmt.Errorf("unknown quirk %q", name)
+	}
+	return nil
+}
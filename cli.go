@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/config"
+	"github.com/tomanta/echip8/host"
+	"github.com/tomanta/echip8/movie"
+	"github.com/tomanta/echip8/palette"
+	"github.com/tomanta/echip8/romdb"
+	"github.com/tomanta/echip8/romfile"
+	"github.com/tomanta/echip8/tui"
+)
+
+const usage = `Usage:
+  gchip run [flags] ROM     run a ROM in a window
+  gchip info [flags] ROM    show what is known about a ROM and the settings it will use
+  gchip disasm [flags] ROM  disassemble a ROM
+  gchip test [flags] ROM    run a ROM without a window and print the screen
+  gchip replay MOVIE ROM    replay a movie without a window and check it ends in sync
+  gchip fonts [FONT]        show the built-in fonts, or one font by name or file
+
+ROM is a path, a file name in ./roms, "-" to read standard input, or a zip
+archive: games.zip if it holds a single ROM, otherwise games.zip/breakout.ch8.
+"gchip ROM" is short for "gchip run ROM". Without a ROM the launcher is shown.
+
+"gchip run -record MOVIE ROM" records a movie, "gchip run -replay MOVIE ROM"
+plays one back in the window.
+
+Run "gchip COMMAND -h" for the flags of a command.
+`
+
+// usageError is returned for bad command lines, it exits with status 2
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// exitCode returns the exit status for the error returned by runCLI: 0 for none or
+// -h, 2 for a bad command line and 1 for anything else
+func exitCode(err error) int {
+	var uerr usageError
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &uerr):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// runCLI runs the command in args (without the program name)
+func runCLI(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return launcherCommand()
+	}
+
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:], stdin)
+	case "info":
+		return infoCommand(args[1:], stdin, stdout)
+	case "disasm":
+		return disasmCommand(args[1:], stdin, stdout)
+	case "test":
+		return testCommand(args[1:], stdin, stdout)
+	case "replay":
+		return replayCommand(args[1:], stdin, stdout)
+	case "fonts":
+		return fontsCommand(args[1:], stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	if strings.HasPrefix(args[0], "-") && args[0] != romfile.Stdin {
+		return usageError{fmt.Sprintf("unknown flag %s before the command\n\n%s", args[0], usage)}
+	}
+	return runCommand(args, stdin)
+}
+
+// settingsFlags are the command line flags that override the config files
+type settingsFlags struct {
+	platform    string
+	speed       int
+	scale       int
+	scaling     string
+	quirks      string
+	font        string
+	memory      int
+	load        int
+	palette     string
+	persistence int
+	effect      string
+	fullscreen  bool
+}
+
+func (f *settingsFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.platform, "platform", "", "platform preset, sets quirks, speed and memory layout (originalChip8, modernChip8, chip48, superchip, ...)")
+	fs.IntVar(&f.speed, "speed", 0, "instructions per frame")
+	fs.IntVar(&f.scale, "scale", 0, "window pixels per CHIP-8 pixel")
+	fs.StringVar(&f.scaling, "scaling", "", "fit the display to the window: "+strings.Join(config.Scalings, ", "))
+	fs.StringVar(&f.quirks, "quirks", "", "comma separated quirks to turn on, prefix with - to turn off (shift,jump,-logic)")
+	fs.StringVar(&f.font, "font", "", "hex font ("+strings.Join(chip8.FontNames(), ", ")+") or the path of a font file")
+	fs.IntVar(&f.memory, "memory", 0, "bytes of memory, 512 to 65536")
+	fs.IntVar(&f.load, "load", 0, "address the ROM is loaded and started at (0x600 for ETI-660 ROMs)")
+	fs.StringVar(&f.palette, "palette", "", "palette name ("+strings.Join(palette.Names(), ", ")+") or foreground and background colours (#33FF33,#000000)")
+	fs.IntVar(&f.persistence, "persistence", 0, "frames a pixel fades over after turning off, reduces flicker (0 to disable)")
+	fs.StringVar(&f.effect, "effect", "", "display effect: "+strings.Join(config.Effects, ", "))
+	fs.BoolVar(&f.fullscreen, "fullscreen", false, "start in fullscreen")
+}
+
+// file returns the flags that were set on the command line as a config layer
+func (f *settingsFlags) file(fs *flag.FlagSet) (config.File, error) {
+	var file config.File
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "platform":
+			file.Platform = &f.platform
+		case "speed":
+			file.Speed.Tickrate = &f.speed
+		case "scale":
+			file.Window.Scale = &f.scale
+		case "font":
+			file.Font = &f.font
+		case "memory":
+			file.Layout.MemorySize = &f.memory
+		case "load":
+			file.Layout.LoadAddress = &f.load
+		case "persistence":
+			file.Display.Persistence = &f.persistence
+		case "effect":
+			file.Display.Effect = &f.effect
+		case "scaling":
+			file.Window.Scaling = &f.scaling
+		case "fullscreen":
+			file.Window.Fullscreen = &f.fullscreen
+		case "quirks":
+			file.Quirks = map[string]bool{}
+			for _, name := range strings.Split(f.quirks, ",") {
+				name = strings.TrimSpace(name)
+				on := !strings.HasPrefix(name, "-")
+				file.Quirks[strings.TrimPrefix(name, "-")] = on
+			}
+		case "palette":
+			fg, bg, ok := strings.Cut(f.palette, ",")
+			if !ok {
+				if strings.HasPrefix(f.palette, "#") {
+					err = usageError{"-palette needs a palette name or two colours: FOREGROUND,BACKGROUND"}
+					return
+				}
+				file.Palette.Name = &f.palette
+				return
+			}
+			file.Palette.Foreground = &fg
+			file.Palette.Background = &bg
+		}
+	})
+	return file, err
+}
+
+// parseRomArgs parses the flags of a command that takes a single ROM and loads it
+func parseRomArgs(fs *flag.FlagSet, args []string, stdin io.Reader) (romfile.ROM, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.Usage()
+			return romfile.ROM{}, err
+		}
+		return romfile.ROM{}, usageError{fmt.Sprintf("%s: %v", fs.Name(), err)}
+	}
+	if fs.NArg() != 1 {
+		return romfile.ROM{}, usageError{fmt.Sprintf("%s needs exactly one ROM, got %d arguments", fs.Name(), fs.NArg())}
+	}
+	return romfile.Load(fs.Arg(0), stdin)
+}
+
+// loadSettings resolves the settings for the ROM, with the command line flags applied last
+func loadSettings(rom romfile.ROM, meta romdb.Metadata, flags *settingsFlags, fs *flag.FlagSet) (config.Settings, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		dir = ""
+	}
+	settings, err := config.Resolve(dir, rom.Name, rom.Path, meta)
+	if err != nil {
+		return config.Settings{}, err
+	}
+	file, err := flags.file(fs)
+	if err != nil {
+		return config.Settings{}, err
+	}
+	if err := settings.Apply("command line", file); err != nil {
+		return config.Settings{}, err
+	}
+	return settings, nil
+}
+
+// newEmulator creates the emulator for the ROM and applies the settings that belong to the core
+func newEmulator(rom romfile.ROM, settings config.Settings) (*chip8.Chip8, error) {
+	emu, err := chip8.New(rom.Data, chip8.WithQuirks(settings.Quirks), chip8.WithFont(settings.Font), chip8.WithLayout(settings.Layout))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rom.Name, err)
+	}
+	return emu, nil
+}
+
+func infoCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	var flags settingsFlags
+	flags.register(fs)
+	rom, err := parseRomArgs(fs, args, stdin)
+	if err != nil {
+		return err
+	}
+	meta := romdb.Lookup(rom.Data)
+	settings, err := loadSettings(rom, meta, &flags, fs)
+	if err != nil {
+		return err
+	}
+
+	title := meta.Title
+	if !meta.Known {
+		title = "(not in the ROM database)"
+	}
+	fmt.Fprintf(stdout, "File:      %s\n", rom.Name)
+	fmt.Fprintf(stdout, "Size:      %d bytes\n", len(rom.Data))
+	fmt.Fprintf(stdout, "SHA-1:     %s\n", meta.SHA1)
+	fmt.Fprintf(stdout, "Title:     %s\n", title)
+	if len(meta.Authors) > 0 {
+		fmt.Fprintf(stdout, "Authors:   %s\n", strings.Join(meta.Authors, ", "))
+	}
+	fmt.Fprintf(stdout, "Platform:  %s\n", settings.Platform)
+	fmt.Fprintf(stdout, "Tickrate:  %d instructions per frame\n", settings.Tickrate)
+	fmt.Fprintf(stdout, "Quirks:    %+v\n", settings.Quirks)
+	fmt.Fprintf(stdout, "Font:      %s\n", settings.Font.Name)
+	l := settings.Layout
+	fmt.Fprintf(stdout, "Memory:    %d bytes, font at 0x%03X, ROM at 0x%03X, start at 0x%03X\n", l.MemorySize, l.FontAddress, l.LoadAddress, l.StartPC)
+	return nil
+}
+
+func disasmCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	var flags settingsFlags
+	flags.register(fs)
+	rom, err := parseRomArgs(fs, args, stdin)
+	if err != nil {
+		return err
+	}
+	settings, err := loadSettings(rom, romdb.Lookup(rom.Data), &flags, fs)
+	if err != nil {
+		return err
+	}
+	for _, line := range chip8.DisassembleROMAt(rom.Data, settings.Layout.LoadAddress) {
+		fmt.Fprintln(stdout, line)
+	}
+	return nil
+}
+
+func testCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var flags settingsFlags
+	flags.register(fs)
+	frames := fs.Int("frames", 180, "number of 60 Hz frames to run before printing the screen")
+	rom, err := parseRomArgs(fs, args, stdin)
+	if err != nil {
+		return err
+	}
+	settings, err := loadSettings(rom, romdb.Lookup(rom.Data), &flags, fs)
+	if err != nil {
+		return err
+	}
+	emu, err := newEmulator(rom, settings)
+	if err != nil {
+		return err
+	}
+
+	runner := host.NewRunner(emu, settings.Tickrate)
+	runErr := runner.RunFrames(*frames)
+	printDisplay(stdout, emu)
+	if runErr != nil {
+		return fmt.Errorf("%s: %w", rom.Name, runErr)
+	}
+	return nil
+}
+
+func replayCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "Usage: gchip replay MOVIE ROM")
+			return err
+		}
+		return usageError{fmt.Sprintf("replay: %v", err)}
+	}
+	if fs.NArg() != 2 {
+		return usageError{fmt.Sprintf("replay needs a movie and a ROM, got %d arguments", fs.NArg())}
+	}
+	m, err := movie.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rom, err := romfile.Load(fs.Arg(1), stdin)
+	if err != nil {
+		return err
+	}
+
+	emu, err := movie.Replay(m, rom.Data)
+	if err == nil || errors.Is(err, movie.ErrDesync) {
+		printDisplay(stdout, emu)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	fmt.Fprintf(stdout, "%d frames in sync, checksum %s\n", m.Frames, m.Checksum)
+	return nil
+}
+
+// fontsCommand prints the characters of every built-in font, or of the one font given
+// by name or path, so fonts can be compared without running a ROM
+func fontsCommand(args []string, stdout io.Writer) error {
+	if len(args) > 1 {
+		return usageError{fmt.Sprintf("fonts takes at most one font, got %d arguments", len(args))}
+	}
+	fonts := chip8.Fonts
+	if len(args) == 1 {
+		s := config.Defaults()
+		if err := s.Apply("command line", config.File{Font: &args[0]}); err != nil {
+			return err
+		}
+		fonts = []chip8.Font{s.Font}
+	}
+	for i, f := range fonts {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "%s\n", f.Name)
+		printGlyphs(stdout, f.Small, 5, 4)
+		if len(f.Big) > 0 {
+			printGlyphs(stdout, f.Big, 10, 8)
+		}
+	}
+	return nil
+}
+
+// printGlyphs prints the characters of a font side by side, each height rows of
+// one byte of which the first width bits are used
+func printGlyphs(w io.Writer, data []byte, height, width int) {
+	for row := range height {
+		var sb strings.Builder
+		for c := 0; c < len(data)/height; c++ {
+			b := data[c*height+row]
+			for bit := range width {
+				if b&(0x80>>bit) != 0 {
+					sb.WriteRune('█')
+				} else {
+					sb.WriteRune(' ')
+				}
+			}
+			sb.WriteRune(' ')
+		}
+		fmt.Fprintln(w, strings.TrimRight(sb.String(), " "))
+	}
+}
+
+// printDisplay draws the display with half block characters, two pixel rows per line
+func printDisplay(w io.Writer, emu *chip8.Chip8) {
+	for _, line := range tui.Render(emu, tui.HalfBlocks) {
+		fmt.Fprintln(w, line)
+	}
+}
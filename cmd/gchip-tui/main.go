@@ -0,0 +1,78 @@
+// Command gchip-tui runs a ROM in the terminal. It does not link the window
+// frontend so it builds and runs on machines without a display server.
+//
+//	gchip-tui [-braille] [-platform NAME] [-speed N] ROM
+//
+// The keys, quirks and speed come from the same config files as gchip. Ctrl-C quits.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/config"
+	"github.com/tomanta/echip8/romdb"
+	"github.com/tomanta/echip8/romfile"
+	"github.com/tomanta/echip8/tui"
+)
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("gchip-tui", flag.ContinueOnError)
+	braille := fs.Bool("braille", false, "draw with braille characters, 2x4 pixels each, for small terminals")
+	platform := fs.String("platform", "", "platform preset, sets quirks, speed and memory layout (originalChip8, modernChip8, chip48, superchip, ...)")
+	speed := fs.Int("speed", 0, "instructions per frame")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("need exactly one ROM, got %d arguments", fs.NArg())
+	}
+
+	rom, err := romfile.Load(fs.Arg(0), nil)
+	if err != nil {
+		return err
+	}
+	dir, err := config.UserDir()
+	if err != nil {
+		dir = ""
+	}
+	settings, err := config.Resolve(dir, rom.Name, rom.Path, romdb.Lookup(rom.Data))
+	if err != nil {
+		return err
+	}
+	var file config.File
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "platform":
+			file.Platform = platform
+		case "speed":
+			file.Speed.Tickrate = speed
+		}
+	})
+	if err := settings.Apply("command line", file); err != nil {
+		return err
+	}
+
+	emu, err := chip8.New(rom.Data, chip8.WithQuirks(settings.Quirks), chip8.WithFont(settings.Font), chip8.WithLayout(settings.Layout))
+	if err != nil {
+		return fmt.Errorf("%s: %w", rom.Name, err)
+	}
+
+	opts := tui.Options{Tickrate: settings.Tickrate, Keymap: settings.Keymap}
+	if *braille {
+		opts.Mode = tui.Braille
+	}
+	return tui.Run(emu, os.Stdin, os.Stdout, opts)
+}
+
+func main() {
+	err := run(os.Args[1:])
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "gchip-tui: %v\n", err)
+	os.Exit(1)
+}
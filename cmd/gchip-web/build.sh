@@ -0,0 +1,13 @@
+#!/bin/sh
+# Builds the browser version into the directory given as the first argument,
+# cmd/gchip-web/dist by default. It only needs the repo and the Go installation
+# (with the modules downloaded), so it works offline. Serve the directory with any
+# static file server, for example: python3 -m http.server -d cmd/gchip-web/dist
+set -e
+cd "$(dirname "$0")"
+out=${1:-dist}
+mkdir -p "$out"
+GOOS=js GOARCH=wasm go build -o "$out/gchip.wasm" .
+cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" "$out/"
+cp index.html "$out/"
+echo "built $out"
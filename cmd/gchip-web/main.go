@@ -0,0 +1,13 @@
+//go:build js && wasm
+
+// Command gchip-web is the browser build of the emulator. Build it with build.sh,
+// which writes gchip.wasm, wasm_exec.js and index.html to a directory that can be
+// served as is.
+package main
+
+import "github.com/tomanta/echip8/web"
+
+func main() {
+	web.Start()
+	select {} // keep the callbacks alive
+}
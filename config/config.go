@@ -0,0 +1,658 @@
+// Package config loads the emulator settings. Settings are layered, each layer
+// overriding the ones before it:
+//
+//  1. the built-in defaults
+//  2. the ROM database entry (see romdb)
+//  3. the global config file: config.json or config.toml in the user config dir
+//  4. the per-ROM file in the user config dir: roms/<rom file name>.json or .toml
+//  5. the per-ROM file next to the ROM: <rom path>.json or .toml
+//  6. anything passed to Settings.Apply, such as command line flags
+//
+// Every setting is optional in every file. Setting the platform resets the quirks
+// tickrate and memory layout to that platform's defaults before the rest of the layer is applied.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/palette"
+	"github.com/tomanta/echip8/romdb"
+	"github.com/tomanta/echip8/romfile"
+)
+
+// File is the contents of a single config file. Nil and empty fields are not set.
+type File struct {
+	Platform *string             `json:"platform,omitempty"`
+	Speed    Speed               `json:"speed,omitempty"`
+	Quirks   map[string]bool     `json:"quirks,omitempty"`
+	Font     *string             `json:"font,omitempty"` // one of chip8.Fonts or the path of a font file
+	Layout   Layout              `json:"layout,omitempty"`
+	Palette  Palette             `json:"palette,omitempty"`
+	Keymap   map[string][]string `json:"keymap,omitempty"` // CHIP-8 key ("0" to "F") to input names, see Settings.Keymap
+	Audio    Audio               `json:"audio,omitempty"`
+	Display  Display             `json:"display,omitempty"`
+	Window   Window              `json:"window,omitempty"`
+	Launcher Launcher            `json:"launcher,omitempty"`
+
+	font *chip8.Font // the font named by Font, loaded by validate
+}
+
+type Speed struct {
+	Tickrate    *int     `json:"tickrate,omitempty"`    // instructions per 60 Hz frame
+	FastForward *float64 `json:"fastForward,omitempty"` // speed while fast-forwarding, 0 for as fast as possible
+	SlowMotion  *float64 `json:"slowMotion,omitempty"`  // speed in slow motion
+}
+
+// Layout moves the font and the ROM in memory or changes the memory size, for ROMs
+// written for platforms such as the ETI-660 that load at 0x600. Setting LoadAddress
+// without StartPC starts the program at the load address.
+type Layout struct {
+	MemorySize  *int `json:"memorySize,omitempty"` // bytes, 512 to 65536
+	FontAddress *int `json:"fontAddress,omitempty"`
+	LoadAddress *int `json:"loadAddress,omitempty"`
+	StartPC     *int `json:"startPC,omitempty"`
+}
+
+// Palette picks a preset by name and then overrides any of its colours
+type Palette struct {
+	Name       *string  `json:"name,omitempty"`       // one of palette.Presets
+	Colors     []string `json:"colors,omitempty"`     // up to four: background, plane 1, plane 2, both planes
+	Foreground *string  `json:"foreground,omitempty"` // "#RRGGBB", the same as the second colour
+	Background *string  `json:"background,omitempty"` // the same as the first colour
+}
+
+type Audio struct {
+	Enabled   *bool    `json:"enabled,omitempty"`
+	Volume    *float64 `json:"volume,omitempty"`    // 0 to 1
+	Frequency *float64 `json:"frequency,omitempty"` // Hz
+}
+
+type Display struct {
+	Persistence *int    `json:"persistence,omitempty"` // frames a pixel fades over after turning off, 0 to disable
+	Effect      *string `json:"effect,omitempty"`      // one of Effects
+	Stats       *bool   `json:"stats,omitempty"`       // show the statistics overlay
+}
+
+type Window struct {
+	Scale      *int    `json:"scale,omitempty"`   // window pixels per CHIP-8 pixel when the window opens
+	Scaling    *string `json:"scaling,omitempty"` // one of Scalings
+	Fullscreen *bool   `json:"fullscreen,omitempty"`
+	Title      *string `json:"title,omitempty"`
+}
+
+type Launcher struct {
+	Dirs []string `json:"dirs,omitempty"` // searched for ROMs as well as ./roms
+}
+
+// Settings are the resolved values of every layer
+type Settings struct {
+	Platform string
+	Tickrate int
+	Quirks   chip8.Quirks
+	Font     chip8.Font
+	Layout   chip8.Layout
+	Speed    SpeedSettings
+	Palette  palette.Palette
+	Keymap   map[byte][]string // input names are up to the frontend, gamepad inputs start with "Pad:"
+	Audio    AudioSettings
+	Display  DisplaySettings
+	Window   WindowSettings
+	Launcher LauncherSettings
+}
+
+// SpeedSettings are the speeds the frontends switch to, as multiples of normal speed
+type SpeedSettings struct {
+	FastForward float64 // 0 runs as fast as possible
+	SlowMotion  float64
+}
+
+type AudioSettings struct {
+	Enabled   bool
+	Volume    float64
+	Frequency float64
+}
+
+type DisplaySettings struct {
+	Persistence int
+	Effect      string
+	Stats       bool
+}
+
+type LauncherSettings struct {
+	Dirs []string
+}
+
+type WindowSettings struct {
+	Scale      int
+	Scaling    string
+	Fullscreen bool
+	Title      string
+}
+
+// Error points at the key in a config file that could not be used
+type Error struct {
+	File string
+	Key  string // dotted path such as "speed.tickrate", empty if the file itself is bad
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.File, e.Key, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// DefaultKeymap is the QWERTY layout shown in the readme
+var DefaultKeymap = map[byte][]string{
+	0x1: {"1"}, 0x2: {"2"}, 0x3: {"3"}, 0xC: {"4"},
+	0x4: {"Q"}, 0x5: {"W"}, 0x6: {"E"}, 0xD: {"R"},
+	0x7: {"A"}, 0x8: {"S"}, 0x9: {"D"}, 0xE: {"F"},
+	0xA: {"Z"}, 0x0: {"X"}, 0xB: {"C"}, 0xF: {"V"},
+}
+
+// Effects are the names of the display effects, the first is the plain display
+var Effects = []string{"none", "scanlines", "grid", "crt"}
+
+// Scalings are the ways the display is fitted to the window: "integer" keeps every
+// CHIP-8 pixel the same size, "fit" fills as much of the window as the aspect ratio
+// allows. Both leave bars around the display where it does not fill the window.
+var Scalings = []string{"integer", "fit"}
+
+// databaseInputs maps the input names used by the ROM database keys to gamepad inputs
+var databaseInputs = map[string]string{
+	"up":    "Pad:Up",
+	"down":  "Pad:Down",
+	"left":  "Pad:Left",
+	"right": "Pad:Right",
+	"a":     "Pad:A",
+	"b":     "Pad:B",
+}
+
+// Defaults returns the settings used when nothing else is known about a ROM
+func Defaults() Settings {
+	keymap := make(map[byte][]string, len(DefaultKeymap))
+	for k, v := range DefaultKeymap {
+		keymap[k] = slices.Clone(v)
+	}
+	p, _ := romdb.LookupPlatform(romdb.DefaultPlatform)
+	return Settings{
+		Platform: p.ID,
+		Tickrate: p.DefaultTickrate,
+		Quirks:   p.Quirks,
+		Font:     chip8.DefaultFont(),
+		Layout:   p.Layout,
+		Speed:    SpeedSettings{FastForward: 4, SlowMotion: 0.25},
+		Palette:  palette.Default(),
+		Keymap:   keymap,
+		Audio:    AudioSettings{Enabled: true, Volume: 0.25, Frequency: 440},
+		Display:  DisplaySettings{Effect: Effects[0]},
+		Window:   WindowSettings{Scale: 10, Scaling: Scalings[0]},
+		Launcher: LauncherSettings{Dirs: []string{romfile.Dir}},
+	}
+}
+
+// UserDir returns the directory holding the global and per-ROM config files
+func UserDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gchip"), nil
+}
+
+// Resolve returns the settings for the ROM with the file name romName read from
+// romPath. dir is the user config dir, an empty dir skips the global and per-ROM
+// files in it. An empty romPath (ROMs from stdin or archives) skips the file next
+// to the ROM.
+func Resolve(dir, romName, romPath string, meta romdb.Metadata) (Settings, error) {
+	s := Defaults()
+	s.Platform = meta.Platform
+	s.Tickrate = meta.Tickrate
+	s.Quirks = meta.Quirks
+	if meta.Layout != (chip8.Layout{}) {
+		s.Layout = meta.Layout
+	}
+	s.Window.Title = meta.Title
+	// The database says which keys a game uses as a d-pad and buttons
+	for name, key := range meta.Keys {
+		if input, ok := databaseInputs[name]; ok && key >= 0 && key <= 0xF {
+			s.Keymap[byte(key)] = append(s.Keymap[byte(key)], input)
+		}
+	}
+	if len(meta.Colors.Pixels) >= 2 {
+		s.Palette.Name = palette.Custom
+		for i, hex := range meta.Colors.Pixels[:min(len(meta.Colors.Pixels), 4)] {
+			c, err := romdb.ParseColor(hex)
+			if err != nil {
+				return Settings{}, err
+			}
+			s.Palette.Colors[i] = c
+		}
+	}
+
+	var bases []string
+	if dir != "" {
+		bases = append(bases, GlobalBase(dir), ROMBase(dir, romName))
+	}
+	if romPath != "" {
+		bases = append(bases, romPath)
+	}
+
+	for _, base := range bases {
+		f, path, err := loadFirst(base)
+		if err != nil {
+			return Settings{}, err
+		}
+		if path == "" {
+			continue
+		}
+		s.apply(f)
+	}
+	return s, nil
+}
+
+// Global returns the settings from the defaults and the global config file in dir,
+// for use before a ROM is chosen
+func Global(dir string) (Settings, error) {
+	s := Defaults()
+	if dir == "" {
+		return s, nil
+	}
+	f, path, err := loadFirst(GlobalBase(dir))
+	if err != nil {
+		return Settings{}, err
+	}
+	if path != "" {
+		s.apply(f)
+	}
+	return s, nil
+}
+
+// GlobalBase returns the global config file in dir, without the extension
+func GlobalBase(dir string) string {
+	return filepath.Join(dir, "config")
+}
+
+// ROMBase returns the config file in dir for the ROM named romName, without the extension
+func ROMBase(dir, romName string) string {
+	return filepath.Join(dir, "roms", romName)
+}
+
+// loadFirst loads base plus ".json" or ".toml", whichever exists. It returns an
+// empty path when neither does.
+func loadFirst(base string) (File, string, error) {
+	var found []string
+	for _, ext := range []string{".json", ".toml"} {
+		if _, err := os.Stat(base + ext); err == nil {
+			found = append(found, base+ext)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return File{}, "", nil
+	case 1:
+		f, err := Load(found[0])
+		return f, found[0], err
+	default:
+		return File{}, "", fmt.Errorf("both %s and %s exist, remove one", found[0], found[1])
+	}
+}
+
+// Load reads a single JSON or TOML config file, chosen by the file extension
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+
+	raw, err := decodeRaw(path, data)
+	if err != nil {
+		return File{}, &Error{File: path, Err: err}
+	}
+
+	if err := checkKeys(reflect.TypeOf(File{}), raw, ""); err != nil {
+		err.File = path
+		return File{}, err
+	}
+
+	// Both formats are decoded through JSON so there is a single set of field names
+	var f File
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return File{}, &Error{File: path, Err: err}
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return File{}, &Error{File: path, Key: typeErr.Field, Err: fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)}
+		}
+		return File{}, &Error{File: path, Err: err}
+	}
+
+	if err := f.validate(filepath.Dir(path)); err != nil {
+		err.File = path
+		return File{}, err
+	}
+	return f, nil
+}
+
+// decodeRaw decodes a config file without checking any of the keys
+func decodeRaw(path string, data []byte) (map[string]any, error) {
+	raw := map[string]any{}
+	var err error
+	switch filepath.Ext(path) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	case ".toml":
+		_, err = toml.Decode(string(data), &raw)
+	default:
+		err = fmt.Errorf("unknown config format, use .json or .toml")
+	}
+	return raw, err
+}
+
+// SaveKeymap replaces the keymap in the config file base.json or base.toml,
+// creating base.toml if neither exists, and returns the file written. The other
+// settings in the file are kept but comments in a TOML file are lost.
+func SaveKeymap(base string, keymap map[byte][]string) (string, error) {
+	_, path, err := loadFirst(base)
+	if err != nil {
+		return "", err
+	}
+	raw := map[string]any{}
+	if path == "" {
+		path = base + ".toml"
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if raw, err = decodeRaw(path, data); err != nil {
+			return "", &Error{File: path, Err: err}
+		}
+	}
+
+	names := map[string]any{}
+	for key, bindings := range keymap {
+		names[fmt.Sprintf("%X", key)] = bindings
+	}
+	raw["keymap"] = names
+
+	var buf bytes.Buffer
+	if filepath.Ext(path) == ".json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(raw)
+	} else {
+		err = toml.NewEncoder(&buf).Encode(raw)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// checkKeys reports the first key in raw that has no matching field in the struct t
+func checkKeys(t reflect.Type, raw map[string]any, prefix string) *Error {
+	fields := map[string]reflect.Type{}
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = t.Field(i).Type
+	}
+	for key, value := range raw {
+		ft, ok := fields[key]
+		if !ok {
+			return &Error{Key: prefix + key, Err: fmt.Errorf("unknown key")}
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		table, ok := value.(map[string]any)
+		if !ok {
+			return &Error{Key: prefix + key, Err: fmt.Errorf("expected a table")}
+		}
+		if err := checkKeys(ft, table, prefix+key+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the values that the JSON types alone don't restrict and loads
+// the font. A relative font path is relative to dir, the directory of the file.
+func (f *File) validate(dir string) *Error {
+	if f.Platform != nil {
+		if _, ok := romdb.LookupPlatform(*f.Platform); !ok {
+			return &Error{Key: "platform", Err: fmt.Errorf("unknown platform %q", *f.Platform)}
+		}
+	}
+	if t := f.Speed.Tickrate; t != nil && (*t < 1 || *t > 100000) {
+		return &Error{Key: "speed.tickrate", Err: fmt.Errorf("must be between 1 and 100000, got %d", *t)}
+	}
+	if v := f.Speed.FastForward; v != nil && *v != 0 && (*v <= 1 || *v > 100) {
+		return &Error{Key: "speed.fastForward", Err: fmt.Errorf("must be 0 (as fast as possible) or between 1 and 100, got %g", *v)}
+	}
+	if v := f.Speed.SlowMotion; v != nil && (*v <= 0 || *v >= 1) {
+		return &Error{Key: "speed.slowMotion", Err: fmt.Errorf("must be between 0 and 1, got %g", *v)}
+	}
+	var q chip8.Quirks
+	for name, on := range f.Quirks {
+		if err := q.Set(name, on); err != nil {
+			return &Error{Key: "quirks." + name, Err: err}
+		}
+	}
+	if name := f.Font; name != nil && f.font == nil {
+		font, err := loadFont(dir, *name)
+		if err != nil {
+			return &Error{Key: "font", Err: err}
+		}
+		f.font = &font
+	}
+	if m := f.Layout.MemorySize; m != nil && (*m < 0x200 || *m > chip8.MaxMemorySize) {
+		return &Error{Key: "layout.memorySize", Err: fmt.Errorf("must be between 512 and %d, got %d", chip8.MaxMemorySize, *m)}
+	}
+	for key, a := range map[string]*int{"layout.fontAddress": f.Layout.FontAddress, "layout.loadAddress": f.Layout.LoadAddress, "layout.startPC": f.Layout.StartPC} {
+		if a != nil && (*a < 0 || *a >= chip8.MaxMemorySize) {
+			return &Error{Key: key, Err: fmt.Errorf("must be between 0x000 and 0xFFFF, got %d", *a)}
+		}
+	}
+	if n := f.Palette.Name; n != nil {
+		if _, ok := palette.Lookup(*n); !ok {
+			return &Error{Key: "palette.name", Err: fmt.Errorf("unknown palette %q, use one of %s", *n, strings.Join(palette.Names(), ", "))}
+		}
+	}
+	if len(f.Palette.Colors) > 4 {
+		return &Error{Key: "palette.colors", Err: fmt.Errorf("at most 4 colours, got %d", len(f.Palette.Colors))}
+	}
+	for _, c := range f.Palette.Colors {
+		if _, err := romdb.ParseColor(c); err != nil {
+			return &Error{Key: "palette.colors", Err: err}
+		}
+	}
+	for key, c := range map[string]*string{"palette.foreground": f.Palette.Foreground, "palette.background": f.Palette.Background} {
+		if c == nil {
+			continue
+		}
+		if _, err := romdb.ParseColor(*c); err != nil {
+			return &Error{Key: key, Err: err}
+		}
+	}
+	for key := range f.Keymap {
+		if _, err := parseKey(key); err != nil {
+			return &Error{Key: "keymap." + key, Err: err}
+		}
+	}
+	if v := f.Audio.Volume; v != nil && (*v < 0 || *v > 1) {
+		return &Error{Key: "audio.volume", Err: fmt.Errorf("must be between 0 and 1, got %g", *v)}
+	}
+	if v := f.Audio.Frequency; v != nil && (*v < 20 || *v > 20000) {
+		return &Error{Key: "audio.frequency", Err: fmt.Errorf("must be between 20 and 20000, got %g", *v)}
+	}
+	if p := f.Display.Persistence; p != nil && (*p < 0 || *p > 60) {
+		return &Error{Key: "display.persistence", Err: fmt.Errorf("must be between 0 and 60, got %d", *p)}
+	}
+	if e := f.Display.Effect; e != nil && !slices.Contains(Effects, *e) {
+		return &Error{Key: "display.effect", Err: fmt.Errorf("unknown effect %q, use one of %s", *e, strings.Join(Effects, ", "))}
+	}
+	if s := f.Window.Scale; s != nil && (*s < 1 || *s > 50) {
+		return &Error{Key: "window.scale", Err: fmt.Errorf("must be between 1 and 50, got %d", *s)}
+	}
+	if s := f.Window.Scaling; s != nil && !slices.Contains(Scalings, *s) {
+		return &Error{Key: "window.scaling", Err: fmt.Errorf("unknown scaling %q, use one of %s", *s, strings.Join(Scalings, ", "))}
+	}
+	return nil
+}
+
+// loadFont returns the built-in font called name, or reads the font file at the path
+// name, relative to dir
+func loadFont(dir, name string) (chip8.Font, error) {
+	if f, ok := chip8.LookupFont(name); ok {
+		return f, nil
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return chip8.Font{}, fmt.Errorf("%q is not a font file or one of %s", name, strings.Join(chip8.FontNames(), ", "))
+		}
+		return chip8.Font{}, err
+	}
+	return chip8.ParseFont(filepath.Base(name), data)
+}
+
+// parseKey converts a CHIP-8 key name ("0" to "F") to its value
+func parseKey(name string) (byte, error) {
+	var key byte
+	if len(name) != 1 {
+		return 0, fmt.Errorf("not a CHIP-8 key, use 0 to F")
+	}
+	if _, err := fmt.Sscanf(name, "%1X", &key); err != nil {
+		return 0, fmt.Errorf("not a CHIP-8 key, use 0 to F")
+	}
+	return key, nil
+}
+
+// Apply validates f and overrides the settings with everything set in it. source
+// is used as the file name in errors, for example "command line". A relative font
+// path is relative to the working directory.
+func (s *Settings) Apply(source string, f File) error {
+	if err := f.validate(""); err != nil {
+		err.File = source
+		return err
+	}
+	s.apply(f)
+	return nil
+}
+
+// apply overrides the settings with everything set in f. f must be validated.
+func (s *Settings) apply(f File) {
+	if f.Platform != nil {
+		p, _ := romdb.LookupPlatform(*f.Platform)
+		s.Platform = p.ID
+		s.Tickrate = p.DefaultTickrate
+		s.Quirks = p.Quirks
+		s.Layout = p.Layout
+	}
+	if f.Layout.MemorySize != nil {
+		s.Layout.MemorySize = *f.Layout.MemorySize
+	}
+	if f.Layout.FontAddress != nil {
+		s.Layout.FontAddress = uint16(*f.Layout.FontAddress)
+	}
+	if f.Layout.LoadAddress != nil {
+		s.Layout.LoadAddress = uint16(*f.Layout.LoadAddress)
+		s.Layout.StartPC = s.Layout.LoadAddress
+	}
+	if f.Layout.StartPC != nil {
+		s.Layout.StartPC = uint16(*f.Layout.StartPC)
+	}
+	if f.Speed.Tickrate != nil {
+		s.Tickrate = *f.Speed.Tickrate
+	}
+	if f.Speed.FastForward != nil {
+		s.Speed.FastForward = *f.Speed.FastForward
+	}
+	if f.Speed.SlowMotion != nil {
+		s.Speed.SlowMotion = *f.Speed.SlowMotion
+	}
+	if f.font != nil {
+		s.Font = *f.font
+	}
+	for name, on := range f.Quirks {
+		s.Quirks.Set(name, on)
+	}
+	if f.Palette.Name != nil {
+		s.Palette, _ = palette.Lookup(*f.Palette.Name)
+	}
+	for i, c := range f.Palette.Colors {
+		s.Palette.Colors[i], _ = romdb.ParseColor(c)
+		s.Palette.Name = palette.Custom
+	}
+	if f.Palette.Background != nil {
+		s.Palette.Colors[0], _ = romdb.ParseColor(*f.Palette.Background)
+		s.Palette.Name = palette.Custom
+	}
+	if f.Palette.Foreground != nil {
+		s.Palette.Colors[1], _ = romdb.ParseColor(*f.Palette.Foreground)
+		s.Palette.Name = palette.Custom
+	}
+	for name, bindings := range f.Keymap {
+		key, _ := parseKey(name)
+		s.Keymap[key] = bindings
+	}
+	if f.Audio.Enabled != nil {
+		s.Audio.Enabled = *f.Audio.Enabled
+	}
+	if f.Audio.Volume != nil {
+		s.Audio.Volume = *f.Audio.Volume
+	}
+	if f.Audio.Frequency != nil {
+		s.Audio.Frequency = *f.Audio.Frequency
+	}
+	if f.Display.Persistence != nil {
+		s.Display.Persistence = *f.Display.Persistence
+	}
+	if f.Display.Effect != nil {
+		s.Display.Effect = *f.Display.Effect
+	}
+	if f.Display.Stats != nil {
+		s.Display.Stats = *f.Display.Stats
+	}
+	if f.Window.Scale != nil {
+		s.Window.Scale = *f.Window.Scale
+	}
+	if f.Window.Scaling != nil {
+		s.Window.Scaling = *f.Window.Scaling
+	}
+	if f.Window.Fullscreen != nil {
+		s.Window.Fullscreen = *f.Window.Fullscreen
+	}
+	if f.Window.Title != nil {
+		s.Window.Title = *f.Window.Title
+	}
+	s.Launcher.Dirs = append(s.Launcher.Dirs, f.Launcher.Dirs...)
+}
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"errors"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/romdb"
+)
+
+func writeFile(t testing.TB, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("json and toml give the same result", func(t *testing.T) {
+		jsonPath := filepath.Join(dir, "a.json")
+		tomlPath := filepath.Join(dir, "a.toml")
+		writeFile(t, jsonPath, `{"speed": {"tickrate": 20}, "quirks": {"shift": true}, "keymap": {"5": ["Up", "W"]}}`)
+		writeFile(t, tomlPath, "[speed]\ntickrate = 20\n[quirks]\nshift = true\n[keymap]\n5 = [\"Up\", \"W\"]\n")
+
+		got, err := Load(tomlPath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want, err := Load(jsonPath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *got.Speed.Tickrate != *want.Speed.Tickrate || got.Quirks["shift"] != want.Quirks["shift"] || len(got.Keymap["5"]) != 2 {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	errorCases := []struct {
+		name     string
+		file     string
+		contents string
+		key      string
+	}{
+		{name: "unknown top level key", file: "b.toml", contents: "speeed = 1\n", key: "speeed"},
+		{name: "unknown nested key", file: "c.json", contents: `{"speed": {"tickrat": 1}}`, key: "speed.tickrat"},
+		{name: "wrong type", file: "d.toml", contents: "[window]\nscale = \"big\"\n", key: "window.scale"},
+		{name: "out of range", file: "e.json", contents: `{"audio": {"volume": 2}}`, key: "audio.volume"},
+		{name: "unknown quirk", file: "f.toml", contents: "[quirks]\nwarp = true\n", key: "quirks.warp"},
+		{name: "bad colour", file: "g.json", contents: `{"palette": {"foreground": "green"}}`, key: "palette.foreground"},
+		{name: "bad chip-8 key", file: "h.toml", contents: "[keymap]\nG = [\"Q\"]\n", key: "keymap.G"},
+		{name: "unknown platform", file: "i.json", contents: `{"platform": "gameboy"}`, key: "platform"},
+		{name: "persistence out of range", file: "j.toml", contents: "[display]\npersistence = -1\n", key: "display.persistence"},
+		{name: "unknown effect", file: "k.json", contents: `{"display": {"effect": "vhs"}}`, key: "display.effect"},
+		{name: "unknown scaling", file: "l.toml", contents: "[window]\nscaling = \"stretch\"\n", key: "window.scaling"},
+		{name: "unknown palette", file: "m.json", contents: `{"palette": {"name": "purple"}}`, key: "palette.name"},
+		{name: "too many colours", file: "n.toml", contents: "[palette]\ncolors = [\"#000\", \"#111\", \"#222\", \"#333\", \"#444\"]\n", key: "palette.colors"},
+		{name: "slow motion too fast", file: "o.json", contents: `{"speed": {"slowMotion": 2}}`, key: "speed.slowMotion"},
+		{name: "unknown font", file: "p.toml", contents: "font = \"comic\"\n", key: "font"},
+		{name: "memory too big", file: "q.json", contents: `{"layout": {"memorySize": 70000}}`, key: "layout.memorySize"},
+		{name: "address past 64K", file: "r.toml", contents: "[layout]\nloadAddress = 0x10000\n", key: "layout.loadAddress"},
+	}
+	for _, test := range errorCases {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.file)
+			writeFile(t, path, test.contents)
+			_, err := Load(path)
+			var cerr *Error
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected a config error, got %v", err)
+			}
+			if cerr.Key != test.key || cerr.File != path {
+				t.Errorf("expected error at %s in %s, got %s in %s", test.key, path, cerr.Key, cerr.File)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	meta := romdb.Metadata{Platform: "originalChip8", Tickrate: 15, Quirks: chip8.Quirks{Logic: true}, Title: "Test"}
+
+	t.Run("no files gives database values", func(t *testing.T) {
+		got, err := Resolve("", "test.ch8", filepath.Join(t.TempDir(), "test.ch8"), meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Tickrate != 15 || got.Quirks != meta.Quirks || got.Window.Title != "Test" {
+			t.Errorf("expected database values, got %+v", got)
+		}
+		if got.Keymap[0xC][0] != "4" {
+			t.Errorf("expected default keymap, got %v", got.Keymap)
+		}
+	})
+
+	t.Run("later layers take precedence", func(t *testing.T) {
+		userDir := t.TempDir()
+		romDir := t.TempDir()
+		romPath := filepath.Join(romDir, "test.ch8")
+		writeFile(t, filepath.Join(userDir, "config.toml"), "[speed]\ntickrate = 30\n[window]\nscale = 5\n[palette]\nforeground = \"#FFB000\"\n")
+		writeFile(t, filepath.Join(userDir, "roms", "test.ch8.json"), `{"speed": {"tickrate": 40}, "keymap": {"5": ["Up"]}}`)
+		writeFile(t, romPath+".toml", "[window]\nscale = 8\n")
+
+		got, err := Resolve(userDir, "test.ch8", romPath, meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Tickrate != 40 {
+			t.Errorf("expected per-ROM tickrate 40, got %d", got.Tickrate)
+		}
+		if got.Window.Scale != 8 {
+			t.Errorf("expected scale 8 from the file next to the ROM, got %d", got.Window.Scale)
+		}
+		if got.Palette.Foreground() != (color.RGBA{0xFF, 0xB0, 0x00, 0xFF}) {
+			t.Errorf("expected global foreground colour, got %v", got.Palette.Foreground())
+		}
+		if got.Keymap[5][0] != "Up" || got.Keymap[4][0] != "Q" {
+			t.Errorf("expected only key 5 to be remapped, got %v", got.Keymap)
+		}
+	})
+
+	t.Run("platform resets quirks and tickrate", func(t *testing.T) {
+		romPath := filepath.Join(t.TempDir(), "test.ch8")
+		writeFile(t, romPath+".json", `{"platform": "chip48", "quirks": {"jump": false}}`)
+
+		got, err := Resolve("", "test.ch8", romPath, meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := chip8.Quirks{Shift: true, MemoryIncrementByX: true}
+		if got.Quirks != want {
+			t.Errorf("expected quirks %+v, got %+v", want, got.Quirks)
+		}
+		if got.Tickrate != 30 {
+			t.Errorf("expected chip48 tickrate 30, got %d", got.Tickrate)
+		}
+	})
+
+	t.Run("layout from the platform and the file", func(t *testing.T) {
+		romPath := filepath.Join(t.TempDir(), "test.ch8")
+		writeFile(t, romPath+".toml", "platform = \"xochip\"\n[layout]\nloadAddress = 0x600\n")
+
+		got, err := Resolve("", "test.ch8", romPath, meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := chip8.Layout{MemorySize: 0x10000, FontAddress: 0x50, LoadAddress: 0x600, StartPC: 0x600}
+		if got.Layout != want {
+			t.Errorf("expected layout %+v, got %+v", want, got.Layout)
+		}
+	})
+
+	t.Run("json and toml for the same rom is an error", func(t *testing.T) {
+		romPath := filepath.Join(t.TempDir(), "test.ch8")
+		writeFile(t, romPath+".json", `{}`)
+		writeFile(t, romPath+".toml", ``)
+		if _, err := Resolve("", "test.ch8", romPath, meta); err == nil {
+			t.Errorf("expected error, did not receive one")
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	s := Defaults()
+	scale := 3
+	if err := s.Apply("command line", File{Window: Window{Scale: &scale}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Window.Scale != 3 {
+		t.Errorf("expected scale 3, got %d", s.Window.Scale)
+	}
+
+	scale = 0
+	err := s.Apply("command line", File{Window: Window{Scale: &scale}})
+	var cerr *Error
+	if !errors.As(err, &cerr) || cerr.Key != "window.scale" || cerr.File != "command line" {
+		t.Errorf("expected error at window.scale from the command line, got %v", err)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	name := "lcd"
+	fg := "#FF0000"
+	s := Defaults()
+	if err := s.Apply("command line", File{Palette: Palette{Name: &name}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Palette.Name != "lcd" || s.Palette.Background() != (color.RGBA{0x9B, 0xBC, 0x0F, 0xFF}) {
+		t.Errorf("expected the lcd preset, got %+v", s.Palette)
+	}
+
+	if err := s.Apply("command line", File{Palette: Palette{Foreground: &fg, Colors: []string{"#000000"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [4]color.RGBA{{0, 0, 0, 0xFF}, {0xFF, 0, 0, 0xFF}, {0x8B, 0xAC, 0x0F, 0xFF}, {0x30, 0x62, 0x30, 0xFF}}
+	if s.Palette.Name != "custom" || s.Palette.Colors != want {
+		t.Errorf("expected the lcd preset with two colours replaced, got %+v", s.Palette)
+	}
+}
+
+func TestFont(t *testing.T) {
+	s := Defaults()
+	if s.Font.Name != chip8.DefaultFont().Name {
+		t.Errorf("expected the default font, got %s", s.Font.Name)
+	}
+	name := "vip"
+	if err := s.Apply("command line", File{Font: &name}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Font.Name != "vip" {
+		t.Errorf("expected the vip font, got %s", s.Font.Name)
+	}
+
+	path := filepath.Join(t.TempDir(), "mine.font")
+	writeFile(t, path, strings.Repeat("\xF0", 80))
+	if err := s.Apply("command line", File{Font: &path}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Font.Name != "mine.font" || len(s.Font.Small) != 80 || s.Font.Big != nil {
+		t.Errorf("expected the font from the file, got %+v", s.Font)
+	}
+
+	// A font path in a config file is relative to the file
+	romPath := filepath.Join(t.TempDir(), "test.ch8")
+	writeFile(t, filepath.Join(filepath.Dir(romPath), "fonts", "next.font"), strings.Repeat("\x90", 80))
+	writeFile(t, romPath+".toml", "font = \"fonts/next.font\"\n")
+	got, err := Resolve("", "test.ch8", romPath, romdb.Metadata{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Font.Name != "next.font" || got.Font.Small[0] != 0x90 {
+		t.Errorf("expected the font next to the config file, got %+v", got.Font)
+	}
+}
+
+func TestGlobal(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.toml"), "[launcher]\ndirs = [\"/games\"]\n")
+	writeFile(t, filepath.Join(dir, "roms", "test.ch8.toml"), "[launcher]\ndirs = [\"/ignored\"]\n")
+
+	got, err := Global(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"roms", "/games"}
+	if strings.Join(got.Launcher.Dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("expected launcher dirs %v, got %v", want, got.Launcher.Dirs)
+	}
+}
+
+func TestDatabaseKeys(t *testing.T) {
+	meta := romdb.Metadata{Platform: "originalChip8", Tickrate: 15, Keys: map[string]int{"left": 4, "a": 5}}
+	got, err := Resolve("", "test.ch8", "", meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"Q", "Pad:Left"}
+	if strings.Join(got.Keymap[4], ",") != strings.Join(want, ",") {
+		t.Errorf("expected key 4 to be bound to %v, got %v", want, got.Keymap[4])
+	}
+	if len(DefaultKeymap[4]) != 1 {
+		t.Errorf("default keymap was changed: %v", DefaultKeymap[4])
+	}
+}
+
+func TestSaveKeymap(t *testing.T) {
+	dir := t.TempDir()
+	keymap := map[byte][]string{0x5: {"ArrowUp", "Pad:Up"}, 0xA: {"Space"}}
+
+	t.Run("creates a toml file", func(t *testing.T) {
+		path, err := SaveKeymap(ROMBase(dir, "new.ch8"), keymap)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if path != ROMBase(dir, "new.ch8")+".toml" {
+			t.Errorf("expected a toml file, got %s", path)
+		}
+		f, err := Load(path)
+		if err != nil {
+			t.Fatalf("saved file does not load: %v", err)
+		}
+		if strings.Join(f.Keymap["5"], ",") != "ArrowUp,Pad:Up" || f.Keymap["A"][0] != "Space" {
+			t.Errorf("keymap not saved, got %v", f.Keymap)
+		}
+	})
+
+	t.Run("keeps other settings in an existing json file", func(t *testing.T) {
+		base := GlobalBase(dir)
+		writeFile(t, base+".json", `{"speed": {"tickrate": 25}, "keymap": {"1": ["Q"]}}`)
+		path, err := SaveKeymap(base, keymap)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		f, err := Load(path)
+		if err != nil {
+			t.Fatalf("saved file does not load: %v", err)
+		}
+		if *f.Speed.Tickrate != 25 {
+			t.Errorf("expected tickrate to be kept")
+		}
+		if _, ok := f.Keymap["1"]; ok {
+			t.Errorf("expected the old keymap to be replaced, got %v", f.Keymap)
+		}
+	})
+
+	t.Run("a cleared key stays cleared", func(t *testing.T) {
+		for _, ext := range []string{".toml", ".json"} {
+			dir := t.TempDir()
+			base := ROMBase(dir, "clear.ch8")
+			if ext == ".json" {
+				writeFile(t, base+ext, `{}`)
+			}
+			if _, err := SaveKeymap(base, map[byte][]string{0x5: {}}); err != nil {
+				t.Fatalf("%s: unexpected error: %v", ext, err)
+			}
+			s, err := Resolve(dir, "clear.ch8", "", romdb.Metadata{Platform: romdb.DefaultPlatform})
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", ext, err)
+			}
+			if len(s.Keymap[5]) != 0 || s.Keymap[4][0] != "Q" {
+				t.Errorf("%s: expected key 5 alone to be cleared, got %v", ext, s.Keymap)
+			}
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	_ "embed"
+	"image"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/tomanta/echip8/config"
+)
+
+//go:embed crt.kage
+var crtSource []byte
+
+// effectUniforms are the crt.kage settings for each of config.Effects, nil draws
+// the display without the shader
+var effectUniforms = map[string]map[string]any{
+	"none":      nil,
+	"scanlines": {"Scanlines": float32(0.8)},
+	"grid":      {"Grid": float32(0.8)},
+	"crt":       {"Scanlines": float32(0.5), "Curvature": float32(0.6), "Bloom": float32(0.35)},
+}
+
+// renderer draws the display as a texture: the pixels are written to an image at
+// one pixel per CHIP-8 pixel which is then scaled to the screen, through the CRT
+// shader if an effect is on. Only the pixels marked dirty are written again.
+type renderer struct {
+	effect       int    // index into config.Effects
+	pixels       []byte // the dirty rectangle, row by row
+	dirty        image.Rectangle
+	all          bool          // every pixel has to be written, for example after a palette change
+	frame        *ebiten.Image // the display at one pixel per CHIP-8 pixel
+	scaled       *ebiten.Image // frame scaled to the screen, the shader input
+	shader       *ebiten.Shader
+	shaderFailed bool
+}
+
+func newRenderer(effect string) *renderer {
+	r := &renderer{}
+	for i, name := range config.Effects {
+		if name == effect {
+			r.effect = i
+		}
+	}
+	return r
+}
+
+// nextEffect switches to the next effect and returns its name
+func (r *renderer) nextEffect() string {
+	r.effect = (r.effect + 1) % len(config.Effects)
+	return config.Effects[r.effect]
+}
+
+// markDirty adds a region of the display that changed since the last draw
+func (r *renderer) markDirty(area image.Rectangle) {
+	r.dirty = r.dirty.Union(area)
+}
+
+// invalidate makes the next draw write every pixel
+func (r *renderer) invalidate() {
+	r.all = true
+}
+
+// displayRect returns where a width by height display goes on a screen_w by
+// screen_h screen, centred, with the rest of the screen left for letterboxing.
+// With integer set only whole multiples of the display size are used.
+func displayRect(screen_w, screen_h, width, height int, integer bool) image.Rectangle {
+	scale := min((float64)(screen_w)/(float64)(width), (float64)(screen_h)/(float64)(height))
+	if integer {
+		scale = max(math.Floor(scale), 1)
+	}
+	w, h := (int)((float64)(width)*scale), (int)((float64)(height)*scale)
+	x, y := (screen_w-w)/2, (screen_h-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// draw renders a width by height display into dst on the screen, pixel returns
+// the colour of the CHIP-8 pixel at x, y
+func (r *renderer) draw(screen *ebiten.Image, dst image.Rectangle, width, height int, pixel func(x, y int) color.RGBA) {
+	bounds := image.Rect(0, 0, width, height)
+	if r.frame == nil || r.frame.Bounds() != bounds {
+		r.frame = ebiten.NewImage(width, height)
+		r.all = true
+	}
+	if r.all {
+		r.dirty, r.all = bounds, false
+	}
+	if area := r.dirty.Intersect(bounds); !area.Empty() {
+		r.pixels = r.pixels[:0]
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				c := pixel(x, y)
+				r.pixels = append(r.pixels, c.R, c.G, c.B, c.A)
+			}
+		}
+		r.frame.SubImage(area).(*ebiten.Image).WritePixels(r.pixels)
+	}
+	r.dirty = image.Rectangle{}
+
+	dst_w, dst_h := dst.Dx(), dst.Dy()
+	var op ebiten.DrawImageOptions
+	op.GeoM.Scale((float64)(dst_w)/(float64)(width), (float64)(dst_h)/(float64)(height))
+
+	uniforms := effectUniforms[config.Effects[r.effect]]
+	if uniforms == nil || !r.loadShader() {
+		op.GeoM.Translate((float64)(dst.Min.X), (float64)(dst.Min.Y))
+		screen.DrawImage(r.frame, &op)
+		return
+	}
+
+	if r.scaled == nil || r.scaled.Bounds().Dx() != dst_w || r.scaled.Bounds().Dy() != dst_h {
+		r.scaled = ebiten.NewImage(dst_w, dst_h)
+	}
+	r.scaled.Clear()
+	r.scaled.DrawImage(r.frame, &op)
+
+	all := map[string]any{"Scale": (float32)(dst_w) / (float32)(width)}
+	for name, value := range uniforms {
+		all[name] = value
+	}
+	shaderOp := &ebiten.DrawRectShaderOptions{
+		Uniforms: all,
+		Images:   [4]*ebiten.Image{r.scaled},
+	}
+	shaderOp.GeoM.Translate((float64)(dst.Min.X), (float64)(dst.Min.Y))
+	screen.DrawRectShader(dst_w, dst_h, r.shader, shaderOp)
+}
+
+// loadShader compiles the CRT shader the first time an effect is used. If it does
+// not compile the effects are turned off rather than failing the game.
+func (r *renderer) loadShader() bool {
+	if r.shader == nil && !r.shaderFailed {
+		var err error
+		if r.shader, err = ebiten.NewShader(crtSource); err != nil {
+			log.Printf("display effects are off: %v", err)
+			r.shaderFailed = true
+		}
+	}
+	return r.shader != nil
+}
@@ -2,11 +2,16 @@ module github.com/tomanta/echip8
 
 go 1.24.6
 
-require github.com/hajimehoshi/ebiten/v2 v2.8.8
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/hajimehoshi/ebiten/v2 v2.8.8
+	golang.org/x/term v0.24.0
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20240911145611-4856209ac325 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.3.3 // indirect
 	github.com/ebitengine/purego v0.8.0 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
 	golang.org/x/sync v0.8.0 // indirect
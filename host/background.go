@@ -0,0 +1,252 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+// Errors returned by BackgroundRunner.Do when the runner isn't running
+var (
+	ErrNotStarted = errors.New("runner not started")
+	ErrStopped    = errors.New("runner stopped")
+)
+
+// Snapshot is the state of the emulator after a host frame. It is a copy that is
+// never changed once published, so any goroutine can read it.
+type Snapshot struct {
+	// Display is the display after the frame. Display.Dirty() is the region changed
+	// since the previous snapshot, a reader that skips snapshots has to redraw all of it.
+	Display      chip8.Framebuffer
+	Registers    [16]uint8
+	PC, Index    uint16
+	Delay, Sound uint8 // the timers
+	Beeping      bool  // the buzzer should sound
+	Frames       int   // emulated frames run
+	Instructions int   // instructions run
+	Paused       bool
+	Err          error // the fault that stopped the emulator, nil while it runs
+}
+
+// fill sets the snapshot to the state of emu, copying the display
+func (s *Snapshot) fill(emu *chip8.Chip8, frames, instructions int, err error) {
+	delay, sound := emu.Timers()
+	*s = Snapshot{
+		Display:      emu.Display,
+		Registers:    emu.Registers,
+		PC:           emu.PC,
+		Index:        emu.Index,
+		Delay:        delay,
+		Sound:        sound,
+		Beeping:      emu.Beeping(),
+		Frames:       frames,
+		Instructions: instructions,
+		Err:          err,
+	}
+}
+
+// BackgroundRunner runs a Runner on its own goroutine. Once started the emulator
+// belongs to that goroutine: other goroutines read Snapshots, press keys, and change
+// the runner through Do, all of which are safe to call concurrently.
+type BackgroundRunner struct {
+	runner   *Runner
+	keys     keyState
+	latest   atomic.Pointer[Snapshot]
+	frames   chan *Snapshot
+	commands chan func(*Runner)
+	started  atomic.Bool
+	done     chan struct{}
+	err      error // set before done is closed
+}
+
+// NewBackgroundRunner returns a runner for emu running ipf instructions per frame.
+// emu must not be used directly once Start is called.
+func NewBackgroundRunner(emu *chip8.Chip8, ipf int) *BackgroundRunner {
+	b := &BackgroundRunner{
+		runner:   NewRunner(emu, ipf),
+		frames:   make(chan *Snapshot, 1),
+		commands: make(chan func(*Runner)),
+		done:     make(chan struct{}),
+	}
+	b.runner.Video = publisher{b}
+	b.runner.Input = &b.keys
+	b.publish(emu, nil)
+	emu.Display.ClearDirty()
+	return b
+}
+
+// Start runs the emulator FrameRate times per second on a new goroutine until ctx
+// is done or the emulator fails. Only the first call starts it, a runner can't be
+// restarted once it stopped.
+func (b *BackgroundRunner) Start(ctx context.Context) {
+	if !b.started.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		b.err = b.loop(ctx)
+		close(b.frames)
+		close(b.done)
+	}()
+}
+
+// loop runs frames and the functions passed to Do until ctx is done or a frame fails.
+// A panic stops the runner like a fault instead of ending the process.
+func (b *BackgroundRunner) loop(ctx context.Context) (err error) {
+	ticker := time.NewTicker(time.Second / FrameRate)
+	defer ticker.Stop()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("emulator panicked: %v", r)
+			b.publish(b.runner.Emulator(), err)
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case f := <-b.commands:
+			f(b.runner)
+			emu := b.runner.Emulator()
+			b.publish(emu, nil)
+			emu.Display.ClearDirty()
+		case <-ticker.C:
+			if err := b.runner.Frame(); err != nil {
+				b.publish(b.runner.Emulator(), err)
+				return err
+			}
+		}
+	}
+}
+
+// Wait blocks until the runner stops and returns the fault that stopped it, nil if
+// ctx ended it
+func (b *BackgroundRunner) Wait() error {
+	<-b.done
+	return b.err
+}
+
+// Done is closed when the runner stops
+func (b *BackgroundRunner) Done() <-chan struct{} {
+	return b.done
+}
+
+// Snapshot returns the latest published state
+func (b *BackgroundRunner) Snapshot() *Snapshot {
+	return b.latest.Load()
+}
+
+// Frames returns a channel that receives a snapshot after every host frame that ran
+// the emulator. A reader that falls behind only gets the newest one. The channel is
+// closed when the runner stops.
+func (b *BackgroundRunner) Frames() <-chan *Snapshot {
+	return b.frames
+}
+
+// Do runs f on the runner's goroutine between frames, for changing the speed,
+// pausing or resetting the emulator, and publishes a snapshot after it. It waits
+// for f to finish. It returns ErrNotStarted before Start and ErrStopped if the
+// runner stopped first, wrapping the fault that stopped it if there was one.
+func (b *BackgroundRunner) Do(f func(r *Runner)) error {
+	if !b.started.Load() {
+		return ErrNotStarted
+	}
+	finished := make(chan struct{})
+	select {
+	case b.commands <- func(r *Runner) { f(r); close(finished) }:
+	case <-b.done:
+		return b.stopped()
+	}
+	select {
+	case <-finished:
+		return nil
+	case <-b.done: // f panicked
+		return b.stopped()
+	}
+}
+
+// stopped returns ErrStopped with the fault that stopped the runner
+func (b *BackgroundRunner) stopped() error {
+	if b.err != nil {
+		return fmt.Errorf("%w: %w", ErrStopped, b.err)
+	}
+	return ErrStopped
+}
+
+// KeyDown presses a key. A key pressed and released before the next emulated frame
+// is still held for that frame, so short taps are not lost.
+func (b *BackgroundRunner) KeyDown(key byte) {
+	b.keys.set(key, true)
+}
+
+// KeyUp releases a key
+func (b *BackgroundRunner) KeyUp(key byte) {
+	b.keys.set(key, false)
+}
+
+// SetKeys sets all keys that are held down, keys not in the slice are released
+func (b *BackgroundRunner) SetKeys(keys []byte) {
+	b.keys.mu.Lock()
+	defer b.keys.mu.Unlock()
+	b.keys.held = [16]bool{}
+	for _, k := range keys {
+		b.keys.held[k&0x0F] = true
+		b.keys.pressed[k&0x0F] = true
+	}
+}
+
+// publish stores a snapshot of emu and offers it on the frames channel, replacing
+// one the reader hasn't taken yet
+func (b *BackgroundRunner) publish(emu *chip8.Chip8, err error) {
+	s := &Snapshot{}
+	s.fill(emu, b.runner.Frames(), b.runner.Instructions(), err)
+	s.Paused = b.runner.Paused()
+	b.latest.Store(s)
+	select {
+	case <-b.frames:
+	default:
+	}
+	b.frames <- s
+}
+
+// publisher is the VideoSink of a BackgroundRunner
+type publisher struct {
+	b *BackgroundRunner
+}
+
+func (p publisher) Present(emu *chip8.Chip8) {
+	p.b.publish(emu, nil)
+}
+
+// keyState is the InputSource of a BackgroundRunner, the keys set from other goroutines
+type keyState struct {
+	mu      sync.Mutex
+	held    [16]bool
+	pressed [16]bool // pressed since the last Keys, reported held for one frame even if released
+}
+
+func (k *keyState) set(key byte, down bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.held[key&0x0F] = down
+	if down {
+		k.pressed[key&0x0F] = true
+	}
+}
+
+func (k *keyState) Keys() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var keys []byte
+	for key, down := range k.held {
+		if down || k.pressed[key] {
+			keys = append(keys, byte(key))
+		}
+	}
+	k.pressed = [16]bool{}
+	return keys
+}
@@ -0,0 +1,137 @@
+package host
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+// batchChunk is the number of machines a worker takes at a time. Chunks keep the
+// workers from contending on the counter when machines are cheap to step.
+const batchChunk = 16
+
+// Batch steps many independent emulators in parallel, for running a ROM sweep or
+// many copies of one ROM without a frontend. Each machine runs headless like
+// Runner.RunFrames: no timing, pause or speed. A machine that fails stops and
+// keeps its error; the others carry on.
+type Batch struct {
+	machines []*chip8.Chip8
+	ipf      int
+	workers  int        // goroutines stepping machines, 0 for GOMAXPROCS
+	states   []Snapshot // the state after the last Step, reused between steps
+}
+
+// NewBatch returns a batch owning machines, each running ipf instructions per frame.
+// The machines must not be used elsewhere while the batch steps them.
+func NewBatch(machines []*chip8.Chip8, ipf int) *Batch {
+	b := &Batch{machines: machines, ipf: ipf, states: make([]Snapshot, len(machines))}
+	for i, m := range machines {
+		b.states[i].fill(m, 0, 0, nil)
+		m.Display.ClearDirty()
+	}
+	return b
+}
+
+// NewBatchFromROM returns a batch of n machines running rom, created with the same
+// options. Seed them differently with Machine(i).Seed for runs that differ.
+func NewBatchFromROM(rom []byte, n, ipf int, opts ...chip8.Option) (*Batch, error) {
+	machines := make([]*chip8.Chip8, n)
+	for i := range machines {
+		m, err := chip8.New(rom, opts...)
+		if err != nil {
+			return nil, err
+		}
+		machines[i] = m
+	}
+	return NewBatch(machines, ipf), nil
+}
+
+// Len returns the number of machines
+func (b *Batch) Len() int {
+	return len(b.machines)
+}
+
+// Machine returns machine i, for setting it up between steps
+func (b *Batch) Machine(i int) *chip8.Chip8 {
+	return b.machines[i]
+}
+
+// SetWorkers sets the number of goroutines Step uses, 0 for GOMAXPROCS
+func (b *Batch) SetWorkers(n int) {
+	b.workers = max(n, 0)
+}
+
+// Step runs frames emulated frames on every machine that hasn't failed. keys holds
+// the keys held on each machine during the step; it is nil for no keys or has one
+// entry per machine, otherwise Step panics.
+//
+// It returns the state of every machine after the step. The slice and the
+// snapshots in it belong to the batch and are overwritten by the next Step; each
+// Display.Dirty() is the region changed during this step.
+func (b *Batch) Step(frames int, keys [][]byte) []Snapshot {
+	if keys != nil && len(keys) != len(b.machines) {
+		panic(fmt.Sprintf("batch of %d machines stepped with keys for %d", len(b.machines), len(keys)))
+	}
+	workers := b.workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, (len(b.machines)+batchChunk-1)/batchChunk)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				start := (int)(next.Add(batchChunk)) - batchChunk
+				if start >= len(b.machines) {
+					return
+				}
+				for i := start; i < min(start+batchChunk, len(b.machines)); i++ {
+					var held []byte
+					if keys != nil {
+						held = keys[i]
+					}
+					b.step(i, frames, held)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return b.states
+}
+
+// step runs frames emulated frames on machine i and updates its state
+func (b *Batch) step(i, frames int, keys []byte) {
+	m, state := b.machines[i], &b.states[i]
+	if state.Err != nil {
+		return
+	}
+	m.SetKeysPressed(keys)
+	run, before := 0, m.Cycles()
+	var err error
+	func() {
+		// A panic on a worker would end the process, make it this machine's error
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("emulator panicked: %v", r)
+			}
+		}()
+		for run < frames && err == nil {
+			err = m.RunFrame(b.ipf)
+			run++
+		}
+	}()
+	state.fill(m, state.Frames+run, state.Instructions+(int)(m.Cycles()-before), err)
+	m.Display.ClearDirty()
+}
+
+// States returns the state of every machine after the last Step, like Step does
+func (b *Batch) States() []Snapshot {
+	return b.states
+}
@@ -0,0 +1,213 @@
+// Package host connects the chip8 core to a frontend. A frontend implements the
+// sinks and sources it supports and a Runner drives the emulator: it owns the
+// timing (instructions per frame, the 60 Hz timers, pause and speed) so every
+// frontend runs ROMs the same way.
+package host
+
+import (
+	"context"
+	"time"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+// FrameRate is the number of frames per second, the rate of the CHIP-8 timers
+const FrameRate = 60
+
+// UncappedBudget is the host time an uncapped Frame spends running the emulator,
+// leaving the rest of the host frame for drawing
+const UncappedBudget = 12 * time.Millisecond
+
+// VideoSink shows the display
+type VideoSink interface {
+	// Present is called after every host frame that ran the emulator. emu must
+	// only be read, and not kept after Present returns. emu.Display.Dirty() is
+	// the region of the display changed since the previous Present.
+	Present(emu *chip8.Chip8)
+}
+
+// AudioSink plays the buzzer
+type AudioSink interface {
+	SetBuzzer(on bool)
+}
+
+// InputSource reports the keypad
+type InputSource interface {
+	// Keys returns the CHIP-8 keys held for the next emulated frame
+	Keys() []byte
+}
+
+// Runner runs the emulator one host frame at a time. Any of the sinks and the
+// source may be nil.
+type Runner struct {
+	emu   *chip8.Chip8
+	ipf   int // instructions per emulated frame
+	Video VideoSink
+	Audio AudioSink
+	Input InputSource
+
+	paused   bool
+	uncapped bool    // run as many frames as fit in UncappedBudget, ignoring speed
+	speed    float64 // emulated frames per host frame
+	owed     float64 // emulated frames not run yet, for speeds that aren't whole numbers
+	frames   int     // emulated frames run
+	executed int     // instructions run
+}
+
+// NewRunner returns a runner for emu running ipf instructions per frame at normal
+// speed. Like SetIPF it runs at least one instruction per frame.
+func NewRunner(emu *chip8.Chip8, ipf int) *Runner {
+	return &Runner{emu: emu, ipf: max(ipf, 1), speed: 1}
+}
+
+// Emulator returns the emulator being run
+func (r *Runner) Emulator() *chip8.Chip8 {
+	return r.emu
+}
+
+// IPF returns the instructions run per frame
+func (r *Runner) IPF() int {
+	return r.ipf
+}
+
+// SetIPF changes the instructions run per frame
+func (r *Runner) SetIPF(ipf int) {
+	r.ipf = max(ipf, 1)
+}
+
+// Paused reports whether the emulator is paused
+func (r *Runner) Paused() bool {
+	return r.paused
+}
+
+// SetPaused pauses or resumes the emulator. The buzzer is silenced while paused.
+func (r *Runner) SetPaused(paused bool) {
+	r.paused = paused
+	if paused && r.Audio != nil {
+		r.Audio.SetBuzzer(false)
+	}
+}
+
+// Speed returns the emulated frames run per host frame, 1 is normal speed
+func (r *Runner) Speed() float64 {
+	return r.speed
+}
+
+// SetSpeed changes the speed: 2 runs two emulated frames every host frame, 0.5 one
+// every other host frame
+func (r *Runner) SetSpeed(speed float64) {
+	if speed > 0 {
+		r.speed = speed
+	}
+}
+
+// Uncapped reports whether the emulator runs as fast as the host allows
+func (r *Runner) Uncapped() bool {
+	return r.uncapped
+}
+
+// SetUncapped switches to running as many emulated frames as fit in UncappedBudget
+// every host frame, or back to the speed
+func (r *Runner) SetUncapped(uncapped bool) {
+	r.uncapped = uncapped
+}
+
+// Frames returns the number of emulated frames run so far
+func (r *Runner) Frames() int {
+	return r.frames
+}
+
+// Instructions returns the number of instructions run so far
+func (r *Runner) Instructions() int {
+	return r.executed
+}
+
+// Frame runs one host frame: as many emulated frames as the speed asks for, each
+// with fresh input, then updates the buzzer and presents the display
+func (r *Runner) Frame() error {
+	if r.paused {
+		return nil
+	}
+	if r.uncapped {
+		defer r.present()
+		start := time.Now()
+		for time.Since(start) < UncappedBudget {
+			if err := r.step(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	r.owed += r.speed
+	n := int(r.owed)
+	r.owed -= float64(n)
+	if n == 0 {
+		return nil
+	}
+	for range n {
+		if err := r.step(); err != nil {
+			r.present()
+			return err
+		}
+	}
+	r.present()
+	return nil
+}
+
+// Step runs a single emulated frame and presents it, even when paused. It is the
+// frame advance for a paused emulator.
+func (r *Runner) Step() error {
+	defer r.present()
+	return r.step()
+}
+
+// step runs a single emulated frame
+func (r *Runner) step() error {
+	if r.Input != nil {
+		r.emu.SetKeysPressed(r.Input.Keys())
+	}
+	r.frames++
+	before := r.emu.Cycles()
+	err := r.emu.RunFrame(r.ipf)
+	r.executed += (int)(r.emu.Cycles() - before)
+	return err
+}
+
+func (r *Runner) present() {
+	if r.Audio != nil {
+		r.Audio.SetBuzzer(r.emu.Beeping())
+	}
+	if r.Video != nil {
+		r.Video.Present(r.emu)
+	}
+	r.emu.Display.ClearDirty()
+}
+
+// RunFrames runs n emulated frames as fast as possible, ignoring pause and speed,
+// and presents the display once at the end. It is meant for headless runs.
+func (r *Runner) RunFrames(n int) error {
+	defer r.present()
+	for range n {
+		if err := r.step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run calls Frame FrameRate times per second until ctx is done or the emulator
+// fails. It is the main loop for frontends that don't have one of their own.
+func (r *Runner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second / FrameRate)
+	defer ticker.Stop()
+	for {
+		if err := r.Frame(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
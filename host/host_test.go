@@ -0,0 +1,383 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+// counter is an infinite loop adding 1 to V0: ADD V0, 1; JP 0x200
+var counter = []byte{0x70, 0x01, 0x12, 0x00}
+
+type fakeSinks struct {
+	presented int
+	buzzer    bool
+	keys      []byte
+	polled    int
+}
+
+func (f *fakeSinks) Present(emu *chip8.Chip8) { f.presented++ }
+func (f *fakeSinks) SetBuzzer(on bool)        { f.buzzer = on }
+func (f *fakeSinks) Keys() []byte             { f.polled++; return f.keys }
+
+func newTestRunner(t testing.TB, rom []byte) (*Runner, *fakeSinks) {
+	emu, err := chip8.NewChip8FromByte(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(emu, 10)
+	f := &fakeSinks{}
+	r.Video, r.Audio, r.Input = f, f, f
+	return r, f
+}
+
+func TestRunnerFrame(t *testing.T) {
+	r, f := newTestRunner(t, counter)
+	r.Frame()
+	r.Frame()
+	// Each frame runs 10 instructions, half of them the ADD
+	if got := r.Emulator().Registers[0]; got != 10 {
+		t.Errorf("expected V0 to be 10, got %d", got)
+	}
+	if f.presented != 2 || f.polled != 2 || r.Frames() != 2 {
+		t.Errorf("expected 2 frames presented and polled, got %d presented, %d polled, %d run", f.presented, f.polled, r.Frames())
+	}
+	if r.Instructions() != 20 {
+		t.Errorf("expected 20 instructions run, got %d", r.Instructions())
+	}
+}
+
+func TestRunnerPause(t *testing.T) {
+	r, f := newTestRunner(t, counter)
+	f.buzzer = true
+	r.SetPaused(true)
+	r.Frame()
+	if r.Frames() != 0 || f.presented != 0 {
+		t.Errorf("expected no frames while paused, got %d", r.Frames())
+	}
+	if f.buzzer {
+		t.Errorf("expected the buzzer to stop when paused")
+	}
+	r.SetPaused(false)
+	r.Frame()
+	if r.Frames() != 1 {
+		t.Errorf("expected a frame after resuming, got %d", r.Frames())
+	}
+}
+
+func TestRunnerSpeed(t *testing.T) {
+	cases := []struct {
+		speed      float64
+		hostFrames int
+		want       int
+	}{
+		{speed: 1, hostFrames: 6, want: 6},
+		{speed: 4, hostFrames: 6, want: 24},
+		{speed: 0.5, hostFrames: 6, want: 3},
+		{speed: 0.25, hostFrames: 6, want: 1},
+	}
+	for _, test := range cases {
+		r, f := newTestRunner(t, counter)
+		r.SetSpeed(test.speed)
+		for range test.hostFrames {
+			r.Frame()
+		}
+		if r.Frames() != test.want || f.polled != test.want {
+			t.Errorf("speed %g: expected %d emulated frames, got %d (input polled %d times)", test.speed, test.want, r.Frames(), f.polled)
+		}
+	}
+}
+
+func TestRunnerStep(t *testing.T) {
+	r, f := newTestRunner(t, counter)
+	r.SetPaused(true)
+	r.Step()
+	r.Frame()
+	r.Step()
+	if r.Frames() != 2 || f.presented != 2 {
+		t.Errorf("expected 2 frames advanced while paused, got %d (%d presented)", r.Frames(), f.presented)
+	}
+	if got := r.Emulator().Registers[0]; got != 10 {
+		t.Errorf("expected V0 to be 10, got %d", got)
+	}
+}
+
+func TestRunnerIPF(t *testing.T) {
+	emu, _ := chip8.NewChip8FromByte(counter)
+	r := NewRunner(emu, 0)
+	if r.IPF() != 1 {
+		t.Errorf("expected at least 1 instruction per frame, got %d", r.IPF())
+	}
+	r.RunFrames(2)
+	if r.Instructions() != 2 {
+		t.Errorf("expected 2 instructions in 2 frames, got %d", r.Instructions())
+	}
+	r.SetIPF(-5)
+	if r.IPF() != 1 {
+		t.Errorf("expected SetIPF to keep at least 1, got %d", r.IPF())
+	}
+}
+
+func TestRunnerUncapped(t *testing.T) {
+	r, f := newTestRunner(t, counter)
+	r.SetUncapped(true)
+	start := time.Now()
+	r.Frame()
+	if elapsed := time.Since(start); elapsed < UncappedBudget {
+		t.Errorf("expected an uncapped frame to use its budget, took %v", elapsed)
+	}
+	if r.Frames() < 2 || f.presented != 1 {
+		t.Errorf("expected many frames presented once, got %d frames presented %d times", r.Frames(), f.presented)
+	}
+}
+
+func TestRunnerBuzzer(t *testing.T) {
+	// LD V1, 2; LD ST, V1; loop
+	r, f := newTestRunner(t, []byte{0x61, 0x02, 0xF1, 0x18, 0x12, 0x04})
+	r.Frame()
+	if !f.buzzer {
+		t.Errorf("expected the buzzer on after setting the sound timer")
+	}
+	r.Frame()
+	if f.buzzer {
+		t.Errorf("expected the buzzer off once the sound timer runs out")
+	}
+}
+
+func TestRunnerRunFrames(t *testing.T) {
+	r, f := newTestRunner(t, counter)
+	if err := r.RunFrames(30); err != nil {
+		t.Fatal(err)
+	}
+	if r.Frames() != 30 || f.presented != 1 {
+		t.Errorf("expected 30 frames presented once, got %d frames presented %d times", r.Frames(), f.presented)
+	}
+
+	bad, _ := newTestRunner(t, []byte{0x00, 0x00})
+	if err := bad.RunFrames(1); err == nil {
+		t.Errorf("expected an error for an unknown instruction")
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	r, _ := newTestRunner(t, counter)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r.Frames() == 0 {
+		t.Errorf("expected frames to run before the context ended")
+	}
+}
+
+func TestBackgroundRunner(t *testing.T) {
+	// Wait for key 5, then loop adding 1 to V1: LD V0, K; ADD V1, 1; JP 0x202
+	rom := []byte{0xF0, 0x0A, 0x71, 0x01, 0x12, 0x02}
+	emu, _ := chip8.NewChip8FromByte(rom)
+	b := NewBackgroundRunner(emu, 10)
+	booted := b.Snapshot()
+	if booted.PC != 0x200 || booted.Frames != 0 {
+		t.Errorf("expected a snapshot of the booted emulator, got PC 0x%03X", booted.PC)
+	}
+	if err := b.Do(func(r *Runner) {}); err != ErrNotStarted {
+		t.Errorf("expected ErrNotStarted before Start, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Start(ctx)
+	b.Start(ctx)
+
+	// Readers on other goroutines while keys are pressed
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				s := b.Snapshot()
+				_ = s.Display.LitCount() + int(s.Registers[1])
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	// A tap between two frames once the wait has started still reaches the program
+	waitForSnapshot(t, b, func(s *Snapshot) bool { return s.Frames > 0 })
+	b.KeyDown(5)
+	b.KeyUp(5)
+	waitForSnapshot(t, b, func(s *Snapshot) bool { return s.Registers[0] == 5 && s.Registers[1] > 0 })
+	wg.Wait()
+
+	if err := b.Do(func(r *Runner) { r.SetPaused(true) }); err != nil {
+		t.Fatal(err)
+	}
+	paused := b.Snapshot()
+	time.Sleep(40 * time.Millisecond)
+	if !paused.Paused || b.Snapshot().Frames != paused.Frames {
+		t.Errorf("expected no frames after pausing, got %d then %d", paused.Frames, b.Snapshot().Frames)
+	}
+	if booted.PC != 0x200 || booted.Frames != 0 || booted.Registers[0] != 0 {
+		t.Errorf("expected published snapshots to stay unchanged, got frame %d", booted.Frames)
+	}
+
+	cancel()
+	if err := b.Wait(); err != nil {
+		t.Errorf("expected no error after cancelling, got %v", err)
+	}
+	if err := b.Do(func(r *Runner) {}); err != ErrStopped {
+		t.Errorf("expected ErrStopped after the runner stopped, got %v", err)
+	}
+	for range b.Frames() {
+		// drained until closed
+	}
+}
+
+// waitForSnapshot reads snapshots from b until one satisfies ok, failing the test
+// if none does within a second
+func waitForSnapshot(t *testing.T, b *BackgroundRunner, ok func(s *Snapshot) bool) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case s, open := <-b.Frames():
+			if !open {
+				t.Fatalf("runner stopped while waiting: %v", b.Wait())
+			}
+			if ok(s) {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("no matching snapshot after a second, last one at frame %d", b.Snapshot().Frames)
+		}
+	}
+}
+
+func TestBackgroundRunnerFault(t *testing.T) {
+	emu, _ := chip8.NewChip8FromByte([]byte{0x00, 0x00})
+	b := NewBackgroundRunner(emu, 10)
+	b.Start(context.Background())
+	if err := b.Wait(); err == nil {
+		t.Fatalf("expected an error for an unknown instruction")
+	}
+	if b.Snapshot().Err == nil {
+		t.Errorf("expected the last snapshot to carry the error")
+	}
+
+	// A stack overflow stops the runner, it doesn't end the process
+	emu, _ = chip8.NewChip8FromByte([]byte{0x22, 0x00}) // CALL 0x200, forever
+	b = NewBackgroundRunner(emu, 10)
+	b.Start(context.Background())
+	if err := b.Wait(); err == nil || !strings.Contains(err.Error(), "stack overflow") {
+		t.Fatalf("expected a stack overflow, got %v", err)
+	}
+	if err := b.Do(func(r *Runner) {}); !errors.Is(err, ErrStopped) || !strings.Contains(err.Error(), "stack overflow") {
+		t.Errorf("expected ErrStopped with the stack overflow, got %v", err)
+	}
+
+	// So does a panic in a function passed to Do
+	emu, _ = chip8.NewChip8FromByte(counter)
+	b = NewBackgroundRunner(emu, 10)
+	b.Start(context.Background())
+	if err := b.Do(func(r *Runner) { panic("oops") }); !errors.Is(err, ErrStopped) || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("expected ErrStopped with the panic, got %v", err)
+	}
+	if err := b.Wait(); err == nil {
+		t.Errorf("expected the panic to stop the runner")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	// Wait for a key, then keep adding it to V1: LD V0, K; ADD V1, V0; JP 0x202
+	rom := []byte{0xF0, 0x0A, 0x81, 0x04, 0x12, 0x02}
+	b, err := NewBatchFromROM(rom, 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetWorkers(3)
+	keys := make([][]byte, b.Len())
+	for i := range keys {
+		keys[i] = []byte{byte(i % 16)}
+	}
+	// The wait starts, then the keys are pressed and released
+	b.Step(1, nil)
+	b.Step(1, keys)
+	states := b.Step(1, nil)
+	for i, s := range states {
+		if s.Err != nil || s.Frames != 3 || s.Registers[0] != byte(i%16) {
+			t.Fatalf("machine %d: expected 3 frames with key %d, got %d frames, V0 %d, error %v", i, i%16, s.Frames, s.Registers[0], s.Err)
+		}
+	}
+
+	// The same machine stepped alone gives the same state
+	alone, _ := chip8.New(rom)
+	alone.RunFrame(10)
+	alone.SetKeysPressed([]byte{7})
+	alone.RunFrame(10)
+	alone.SetKeysPressed(nil)
+	alone.RunFrame(10)
+	if states[7].Registers != alone.Registers || states[7].Instructions != (int)(alone.Cycles()) {
+		t.Errorf("expected machine 7 to match a machine run alone, got %v and %v", states[7].Registers, alone.Registers)
+	}
+
+	// A fault stops only the failing machine
+	b.Machine(3).PC = 0x300
+	states = b.Step(1, nil)
+	if states[3].Err == nil || states[4].Err != nil {
+		t.Errorf("expected machine 3 alone to fail, got %v and %v", states[3].Err, states[4].Err)
+	}
+	frames := states[3].Frames
+	if states = b.Step(1, nil); states[3].Frames != frames || states[4].Frames != 5 {
+		t.Errorf("expected the failed machine to stay stopped")
+	}
+}
+
+func TestBatchStackOverflow(t *testing.T) {
+	good, _ := chip8.New(counter)
+	calls, _ := chip8.New([]byte{0x22, 0x00}) // CALL 0x200, forever
+	b := NewBatch([]*chip8.Chip8{good, calls}, 10)
+	states := b.Step(5, nil)
+	if states[0].Err != nil || states[0].Frames != 5 {
+		t.Errorf("expected the good machine to run 5 frames, got %d and %v", states[0].Frames, states[0].Err)
+	}
+	if states[1].Err == nil || !strings.Contains(states[1].Err.Error(), "stack overflow") {
+		t.Errorf("expected a stack overflow, got %v", states[1].Err)
+	}
+	if states = b.Step(1, nil); states[0].Frames != 6 || states[1].Frames != 2 {
+		t.Errorf("expected only the good machine to carry on, got %d and %d frames", states[0].Frames, states[1].Frames)
+	}
+}
+
+func BenchmarkBatch(b *testing.B) {
+	rom, err := os.ReadFile("../roms/breakout.ch8")
+	if err != nil {
+		b.Skip(err)
+	}
+	procs := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(procs)
+	for _, p := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("procs=%d", p), func(b *testing.B) {
+			runtime.GOMAXPROCS(p)
+			batch, err := NewBatchFromROM(rom, 1024, 15)
+			if err != nil {
+				b.Fatal(err)
+			}
+			keys := make([][]byte, batch.Len())
+			for i := range keys {
+				batch.Machine(i).Seed(uint64(i))
+				keys[i] = []byte{byte(4 + i%3*2)} // left, none, right
+			}
+			b.ResetTimer()
+			for b.Loop() {
+				batch.Step(1, keys)
+			}
+			b.ReportMetric(float64(b.N*batch.Len())/b.Elapsed().Seconds(), "frames/s")
+		})
+	}
+}
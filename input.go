@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// padPrefix marks gamepad bindings in the keymap, for example "Pad:A" or "Pad:LeftX-"
+const padPrefix = "Pad:"
+
+// axisThreshold is how far a stick has to be pushed to count as pressed
+const axisThreshold = 0.5
+
+// padButtons are the gamepad button names, using the standard (Xbox style) layout
+var padButtons = map[string]ebiten.StandardGamepadButton{
+	"A":      ebiten.StandardGamepadButtonRightBottom,
+	"B":      ebiten.StandardGamepadButtonRightRight,
+	"X":      ebiten.StandardGamepadButtonRightLeft,
+	"Y":      ebiten.StandardGamepadButtonRightTop,
+	"L1":     ebiten.StandardGamepadButtonFrontTopLeft,
+	"R1":     ebiten.StandardGamepadButtonFrontTopRight,
+	"L2":     ebiten.StandardGamepadButtonFrontBottomLeft,
+	"R2":     ebiten.StandardGamepadButtonFrontBottomRight,
+	"Select": ebiten.StandardGamepadButtonCenterLeft,
+	"Start":  ebiten.StandardGamepadButtonCenterRight,
+	"L3":     ebiten.StandardGamepadButtonLeftStick,
+	"R3":     ebiten.StandardGamepadButtonRightStick,
+	"Up":     ebiten.StandardGamepadButtonLeftTop,
+	"Down":   ebiten.StandardGamepadButtonLeftBottom,
+	"Left":   ebiten.StandardGamepadButtonLeftLeft,
+	"Right":  ebiten.StandardGamepadButtonLeftRight,
+}
+
+// padAxes are the stick directions. Up on a stick is a negative value.
+var padAxes = map[string]padAxis{
+	"LeftX-":  {ebiten.StandardGamepadAxisLeftStickHorizontal, -1},
+	"LeftX+":  {ebiten.StandardGamepadAxisLeftStickHorizontal, 1},
+	"LeftY-":  {ebiten.StandardGamepadAxisLeftStickVertical, -1},
+	"LeftY+":  {ebiten.StandardGamepadAxisLeftStickVertical, 1},
+	"RightX-": {ebiten.StandardGamepadAxisRightStickHorizontal, -1},
+	"RightX+": {ebiten.StandardGamepadAxisRightStickHorizontal, 1},
+	"RightY-": {ebiten.StandardGamepadAxisRightStickVertical, -1},
+	"RightY+": {ebiten.StandardGamepadAxisRightStickVertical, 1},
+}
+
+type padAxis struct {
+	axis      ebiten.StandardGamepadAxis
+	direction float64
+}
+
+// binding is a single keyboard key, gamepad button or stick direction
+type binding struct {
+	name   string // as written in the config
+	key    ebiten.Key
+	button ebiten.StandardGamepadButton
+	axis   padAxis
+	kind   bindingKind
+}
+
+type bindingKind int
+
+const (
+	keyBinding bindingKind = iota
+	buttonBinding
+	axisBinding
+)
+
+// parseBinding reads a keymap entry: an Ebiten key name ("Q", "ArrowUp"), or a
+// gamepad button or stick direction prefixed with "Pad:" ("Pad:A", "Pad:LeftY-")
+func parseBinding(name string) (binding, error) {
+	if pad, ok := strings.CutPrefix(name, padPrefix); ok {
+		if b, ok := padButtons[pad]; ok {
+			return binding{name: name, button: b, kind: buttonBinding}, nil
+		}
+		if a, ok := padAxes[pad]; ok {
+			return binding{name: name, axis: a, kind: axisBinding}, nil
+		}
+		return binding{}, fmt.Errorf("unknown gamepad input %q", name)
+	}
+	var k ebiten.Key
+	if err := k.UnmarshalText([]byte(name)); err != nil {
+		return binding{}, fmt.Errorf("unknown key %q", name)
+	}
+	return binding{name: name, key: k, kind: keyBinding}, nil
+}
+
+// pressed reports whether the input is held, on the keyboard or any standard gamepad
+func (b binding) pressed(pads []ebiten.GamepadID) bool {
+	switch b.kind {
+	case keyBinding:
+		return ebiten.IsKeyPressed(b.key)
+	case buttonBinding:
+		return slices.ContainsFunc(pads, func(id ebiten.GamepadID) bool {
+			return ebiten.IsStandardGamepadButtonPressed(id, b.button)
+		})
+	case axisBinding:
+		return slices.ContainsFunc(pads, func(id ebiten.GamepadID) bool {
+			return ebiten.StandardGamepadAxisValue(id, b.axis.axis)*b.axis.direction > axisThreshold
+		})
+	}
+	return false
+}
+
+// Keymap maps each CHIP-8 key to any number of inputs
+type Keymap map[byte][]binding
+
+// parseKeymap converts the names from the config to bindings
+func parseKeymap(names map[byte][]string) (Keymap, error) {
+	keymap := make(Keymap, len(names))
+	for key, bindings := range names {
+		keymap[key] = nil // a key with no bindings stays in the map, see Keymap.names
+		for _, name := range bindings {
+			b, err := parseBinding(name)
+			if err != nil {
+				return nil, fmt.Errorf("keymap.%X: %w", key, err)
+			}
+			keymap[key] = append(keymap[key], b)
+		}
+	}
+	return keymap, nil
+}
+
+// names converts the keymap back to the form used in the config. Keys with no
+// bindings are kept as empty lists, so a saved config clears them instead of
+// leaving them to the default keymap.
+func (k Keymap) names() map[byte][]string {
+	names := make(map[byte][]string, len(k))
+	for key, bindings := range k {
+		names[key] = []string{}
+		for _, b := range bindings {
+			names[key] = append(names[key], b.name)
+		}
+	}
+	return names
+}
+
+// standardPads returns the connected gamepads that have a standard layout
+func standardPads() []ebiten.GamepadID {
+	var pads []ebiten.GamepadID
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			pads = append(pads, id)
+		}
+	}
+	return pads
+}
+
+// Pressed returns the CHIP-8 keys that have at least one held input
+func (k Keymap) Pressed() []byte {
+	pads := standardPads()
+	var keys []byte
+	for key, bindings := range k {
+		for _, b := range bindings {
+			if b.pressed(pads) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// justPressedBinding returns the first input pressed this tick, used when rebinding
+func justPressedBinding() (binding, bool) {
+	if keys := inpututil.AppendJustPressedKeys(nil); len(keys) > 0 {
+		name, _ := keys[0].MarshalText()
+		return binding{name: string(name), key: keys[0], kind: keyBinding}, true
+	}
+	for _, id := range standardPads() {
+		for name, b := range padButtons {
+			if inpututil.IsStandardGamepadButtonJustPressed(id, b) {
+				return binding{name: padPrefix + name, button: b, kind: buttonBinding}, true
+			}
+		}
+		for name, a := range padAxes {
+			if ebiten.StandardGamepadAxisValue(id, a.axis)*a.direction > 0.9 {
+				return binding{name: padPrefix + name, axis: a, kind: axisBinding}, true
+			}
+		}
+	}
+	return binding{}, false
+}
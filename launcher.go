@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/tomanta/echip8/romdb"
+	"github.com/tomanta/echip8/romfile"
+)
+
+const (
+	launcherLineHeight = 16 // height of the ebitenutil debug font
+	launcherRows       = 16 // ROMs shown at once
+	launcherRepeat     = 30 // ticks before a held key starts repeating
+)
+
+// launcherEntry is a ROM found while scanning the launcher directories
+type launcherEntry struct {
+	path  string
+	title string
+}
+
+// Launcher lists the ROMs in the configured directories and lets the user pick one
+type Launcher struct {
+	entries  []launcherEntry
+	cursor   int
+	top      int    // first entry shown
+	selected string // path picked by the user, cleared by the App once booted
+	status   string // shown at the bottom, used for errors
+}
+
+// newLauncher scans dirs for ROMs. Directories that cannot be read are reported
+// in the status line rather than failing.
+func newLauncher(dirs []string) *Launcher {
+	l := &Launcher{}
+	seen := map[string]bool{}
+	var problems []string
+	for _, dir := range dirs {
+		paths, err := romfile.Scan(dir)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		for _, p := range paths {
+			abs, _ := filepath.Abs(p)
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+			l.entries = append(l.entries, launcherEntry{path: p, title: romTitle(p)})
+		}
+	}
+	if len(problems) > 0 {
+		l.status = strings.Join(problems, "; ")
+	} else if len(l.entries) == 0 {
+		l.status = fmt.Sprintf("no roms found in %s", strings.Join(dirs, ", "))
+	}
+	return l
+}
+
+// romTitle returns the title from the ROM database, falling back to the file name
+func romTitle(path string) string {
+	name := filepath.Base(filepath.FromSlash(path))
+	rom, err := romfile.Load(path, nil)
+	if err != nil {
+		return name
+	}
+	meta := romdb.Lookup(rom.Data)
+	if !meta.Known || meta.Title == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", meta.Title, name)
+}
+
+// pressed reports a key that was just pressed or has been held long enough to repeat
+func pressed(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d > launcherRepeat && d%4 == 0)
+}
+
+func (l *Launcher) Update() {
+	if len(l.entries) == 0 {
+		return
+	}
+	switch {
+	case pressed(ebiten.KeyArrowUp):
+		l.cursor--
+	case pressed(ebiten.KeyArrowDown):
+		l.cursor++
+	case pressed(ebiten.KeyPageUp):
+		l.cursor -= launcherRows
+	case pressed(ebiten.KeyPageDown):
+		l.cursor += launcherRows
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		l.cursor = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		l.cursor = len(l.entries) - 1
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter), inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		l.selected = l.entries[l.cursor].path
+	}
+	l.cursor = max(0, min(l.cursor, len(l.entries)-1))
+
+	// Scroll so the cursor is always visible
+	if l.cursor < l.top {
+		l.top = l.cursor
+	}
+	if l.cursor >= l.top+launcherRows {
+		l.top = l.cursor - launcherRows + 1
+	}
+}
+
+func (l *Launcher) Draw(screen *ebiten.Image) {
+	ebitenutil.DebugPrintAt(screen, "Pick a ROM: arrows to move, Enter to play, Esc in a game returns here", 4, 0)
+	for i := l.top; i < min(l.top+launcherRows, len(l.entries)); i++ {
+		prefix := "  "
+		if i == l.cursor {
+			prefix = "> "
+		}
+		y := (i - l.top + 1) * launcherLineHeight
+		ebitenutil.DebugPrintAt(screen, prefix+l.entries[i].title, 4, y)
+	}
+	if l.status != "" {
+		ebitenutil.DebugPrintAt(screen, l.status, 4, (launcherRows+2)*launcherLineHeight)
+	}
+}
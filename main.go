@@ -1,127 +1,246 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"image/color"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/config"
+	"github.com/tomanta/echip8/host"
+	"github.com/tomanta/echip8/movie"
+	"github.com/tomanta/echip8/palette"
+	"github.com/tomanta/echip8/phosphor"
 )
 
+// letterbox is the colour of the bars around the display when it doesn't fill the window
+var letterbox = color.Black
+
+// Game is the Ebitengine frontend for a running ROM. It is the video and audio sink
+// and the input source of a host.Runner, which runs the emulator.
 type Game struct {
-	emu chip8.Chip8
+	emu      *chip8.Chip8
+	runner   *host.Runner
+	display  chip8.Framebuffer // the display as of the last frame presented
+	keymap   Keymap
+	held     []byte // keys read at the start of the host frame, used for every emulated frame in it
+	speed    config.SpeedSettings
+	fast     bool              // the fast-forward key is held
+	slow     bool              // slow motion is on
+	turbo    bool              // running as fast as possible
+	palettes []palette.Palette // the configured palette and the presets, cycled with a hotkey
+	palette  int               // index into palettes
+	phosphor *phosphor.Filter  // nil when persistence is off
+	faded    int               // the runner's frame count when the phosphor was last updated
+	renderer *renderer
+	beeper   *beeper
+	window   config.WindowSettings
+	osd      osd
+
+	recorder *movie.Recorder // set while recording a movie
+	player   *movie.Player   // set while replaying a movie, live input is ignored
+	replay   *movie.Movie
 }
 
-func (g *Game) getKeys() []byte {
-	var keys []byte
-	// 123C
-	// 456D
-	// 789E
-	// A0BF
-	if ebiten.IsKeyPressed(ebiten.Key1) {
-		keys = append(keys, 0x1)
-	}
-	if ebiten.IsKeyPressed(ebiten.Key2) {
-		keys = append(keys, 0x2)
-	}
-	if ebiten.IsKeyPressed(ebiten.Key3) {
-		keys = append(keys, 0x3)
-	}
-	if ebiten.IsKeyPressed(ebiten.Key4) {
-		keys = append(keys, 0xC)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyQ) {
-		keys = append(keys, 0x4)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyW) {
-		keys = append(keys, 0x5)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyE) {
-		keys = append(keys, 0x6)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyR) {
-		keys = append(keys, 0xD)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyA) {
-		keys = append(keys, 0x7)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyS) {
-		keys = append(keys, 0x8)
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyD) {
-		keys = append(keys, 0x9)
+// Update runs one frame. The window runs at 60 TPS, so this is also when the timers tick.
+// An emulator fault pauses the game and is shown in a banner rather than closing the window.
+func (g *Game) Update() error {
+	g.osd.update(g.runner)
+	g.held = g.keymap.Pressed()
+	if err := g.runner.Frame(); err != nil {
+		g.fail(err)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyF) {
-		keys = append(keys, 0xE)
+	return nil
+}
+
+// fail pauses the game after an emulator fault and reports it
+func (g *Game) fail(err error) {
+	log.Printf("emulator: %v", err)
+	g.runner.SetPaused(true)
+	g.osd.fail(err)
+}
+
+// reset reboots the ROM, resuming it if it was paused by a fault. Movies start
+// from power on, so a game recording or replaying one can't be reset.
+func (g *Game) reset() {
+	if g.recorder != nil || g.player != nil {
+		g.osd.show("Can't reset while a movie is running")
+		return
+	}
+	g.emu.Reset()
+	if g.osd.fault != "" {
+		g.osd.fault = ""
+		g.runner.SetPaused(false)
+	}
+	g.osd.show("Reset")
+}
+
+// applySpeed sets the speed of the runner from the speed controls. Fast-forward
+// wins over slow motion.
+func (g *Game) applySpeed() {
+	g.runner.SetUncapped(g.turbo || (g.fast && g.speed.FastForward == 0))
+	switch {
+	case g.fast && g.speed.FastForward > 0:
+		g.runner.SetSpeed(g.speed.FastForward)
+	case g.slow:
+		g.runner.SetSpeed(g.speed.SlowMotion)
+	default:
+		g.runner.SetSpeed(1)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyZ) {
-		keys = append(keys, 0xA)
+}
+
+// Keys returns the keys held on the keyboard and gamepads, or the recorded keys
+// while replaying a movie
+func (g *Game) Keys() []byte {
+	keys := g.held
+	if g.player != nil {
+		if recorded, ok := g.player.Next(); ok {
+			keys = recorded
+		} else {
+			g.endReplay()
+		}
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyX) {
-		keys = append(keys, 0x0)
+	if g.recorder != nil {
+		g.recorder.Frame(keys)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyC) {
-		keys = append(keys, 0xB)
+	return keys
+}
+
+func (g *Game) SetBuzzer(on bool) {
+	if g.beeper != nil {
+		g.beeper.SetOn(on)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyV) {
-		keys = append(keys, 0xF)
+}
+
+func (g *Game) Present(emu *chip8.Chip8) {
+	if !emu.Display.Dirty().Empty() {
+		g.display = emu.Display
+		g.renderer.markDirty(emu.Display.Dirty())
+	}
+	if g.phosphor != nil {
+		// Fading pixels change colour every frame. Fade by the emulated frames run
+		// since the last Present, which is several when fast-forwarding.
+		width, height := g.displaySize()
+		frames := g.runner.Frames()
+		g.phosphor.UpdateFrames(frames-g.faded, width, height, g.display.Lit)
+		g.faded = frames
+		g.renderer.invalidate()
 	}
+}
 
-	return keys
+func (g *Game) Draw(screen *ebiten.Image) {
+	width, height := g.displaySize()
+	bounds := screen.Bounds()
+	dst := displayRect(bounds.Dx(), bounds.Dy(), width, height, g.window.Scaling == "integer")
+	screen.Fill(letterbox)
+	g.renderer.draw(screen, dst, width, height, g.pixelColor)
+	g.osd.draw(screen, g)
 }
 
-func (g *Game) Update() error {
-	g.emu.SetKeysPressed(g.getKeys())
-	g.emu.Update()
-	return nil
+// displaySize returns the resolution of the emulated display, 64x32 or 128x64 in
+// the extended modes
+func (g *Game) displaySize() (int, int) {
+	return g.display.Width(), g.display.Height()
 }
 
-func (g *Game) Draw(screen *ebiten.Image) {
-	var scale float32 = 10
-	screen.Clear()
-	for x := range 64 {
-		var x_pos float32 = (float32)(x) * scale
-		for y := range 32 {
-			var y_pos float32 = (float32)(y) * scale
-			if g.emu.Display[x][y] {
-				vector.DrawFilledRect(screen, x_pos, y_pos, 10, 10, color.RGBA{51, 255, 51, 0}, false)
-			}
-		}
-	}
+// pixelColor returns the colour of the CHIP-8 pixel at x, y
+func (g *Game) pixelColor(x, y int) color.RGBA {
+	p := g.palettes[g.palette]
+	planes := g.display.Pixel(x, y)
+	if planes == 0 && g.phosphor != nil {
+		// The filter only keeps how bright a pixel is, fading pixels use the foreground
+		return g.phosphor.Color(x, y, p.Foreground(), p.Background())
+	}
+	return p.Pixel(planes)
+}
+
+// nextPalette switches to the next palette and returns its name
+func (g *Game) nextPalette() string {
+	g.palette = (g.palette + 1) % len(g.palettes)
+	g.renderer.invalidate()
+	return g.palettes[g.palette].Name
 }
 
-func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return 640, 320
+// endReplay checks the end state of a replayed movie and hands control back to the player
+func (g *Game) endReplay() {
+	if err := g.replay.Verify(g.emu); err != nil {
+		log.Printf("replay: %v", err)
+		g.osd.show("Replay: %v", err)
+	} else {
+		log.Printf("replay: finished %d frames in sync", g.replay.Frames)
+		g.osd.show("Replay finished %d frames in sync", g.replay.Frames)
+	}
+	g.player = nil
+	g.replay = nil
 }
 
-func openRom(name string) []byte {
-	path := filepath.Join(".", "roms", name)
-	data, err := os.ReadFile(path)
+// newGame configures the emulator and the frontend from the resolved settings
+func newGame(emu *chip8.Chip8, settings config.Settings) (*Game, error) {
+	keymap, err := parseKeymap(settings.Keymap)
 	if err != nil {
-		panic("could not open rom")
+		return nil, err
+	}
+	g := &Game{
+		emu:      emu,
+		display:  emu.Display,
+		keymap:   keymap,
+		speed:    settings.Speed,
+		palettes: palette.Cycle(settings.Palette),
+		window:   settings.Window,
+		renderer: newRenderer(settings.Display.Effect),
+	}
+	g.osd.stats = settings.Display.Stats
+	g.runner = host.NewRunner(g.emu, settings.Tickrate)
+	g.runner.Video, g.runner.Audio, g.runner.Input = g, g, g
+	if settings.Display.Persistence > 0 {
+		g.phosphor = phosphor.New(settings.Display.Persistence)
+	}
+
+	if settings.Audio.Enabled {
+		g.beeper, err = newBeeper(settings.Audio.Frequency, settings.Audio.Volume)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return data
+	return g, nil
 }
 
-func getRomName() string {
-	result := "ibm_logo.ch8"
-	if len(os.Args) > 1 {
-		result = os.Args[1]
+func runCommand(args []string, stdin io.Reader) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	var flags settingsFlags
+	flags.register(fs)
+	record := fs.String("record", "", "record the input to a movie file")
+	replay := fs.String("replay", "", "replay a movie file recorded with this ROM")
+	rom, err := parseRomArgs(fs, args, stdin)
+	if err != nil {
+		return err
+	}
+	app, err := newApp(&flags, fs)
+	if err != nil {
+		return err
+	}
+	if *record != "" && *replay != "" {
+		return usageError{"-record and -replay cannot be used together"}
+	}
+	app.recordPath = *record
+	if *replay != "" {
+		if app.replay, err = movie.Load(*replay); err != nil {
+			return err
+		}
 	}
-	return result
+	return runWindow(app, rom)
 }
 
 func main() {
-	ebiten.SetWindowSize(640, 320)
-	ebiten.SetTPS(700)
-
-	romData := openRom(getRomName())
-	emu, _ := chip8.NewChip8FromByte(romData)
-
-	if err := ebiten.RunGame(&Game{emu: emu}); err != nil {
-		log.Fatal(err)
+	err := runCLI(os.Args[1:], os.Stdin, os.Stdout)
+	code := exitCode(err)
+	if code == 0 {
+		return
 	}
+	fmt.Fprintf(os.Stderr, "gchip: %v\n", err)
+	os.Exit(code)
 }
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomanta/echip8/config"
+)
+
+// isolateConfig points the user config dir at an empty directory so the tests don't
+// read the config files of whoever runs them
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+}
+
+func TestParseRomArgs(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  []string
+		stdin string
+		rom   string // the name of the loaded ROM, empty if an error is expected
+		usage bool   // the error is a usage error
+	}{
+		{name: "rom path", args: []string{"roms/ibm_logo.ch8"}, rom: "ibm_logo.ch8"},
+		{name: "name in ./roms", args: []string{"ibm_logo.ch8"}, rom: "ibm_logo.ch8"},
+		{name: "flags before the rom", args: []string{"-speed", "20", "roms/ibm_logo.ch8"}, rom: "ibm_logo.ch8"},
+		{name: "standard input", args: []string{"-"}, stdin: "\x12\x00", rom: "stdin"},
+		{name: "no rom", args: nil, usage: true},
+		{name: "two roms", args: []string{"a.ch8", "b.ch8"}, usage: true},
+		{name: "unknown flag", args: []string{"-sped", "20", "a.ch8"}, usage: true},
+		{name: "bad flag value", args: []string{"-speed", "fast", "a.ch8"}, usage: true},
+		{name: "missing file", args: []string{"missing.ch8"}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			var flags settingsFlags
+			flags.register(fs)
+			rom, err := parseRomArgs(fs, test.args, strings.NewReader(test.stdin))
+			if test.rom != "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if rom.Name != test.rom || len(rom.Data) == 0 {
+					t.Errorf("expected ROM %s, got %s with %d bytes", test.rom, rom.Name, len(rom.Data))
+				}
+				return
+			}
+			var uerr usageError
+			if err == nil || errors.As(err, &uerr) != test.usage {
+				t.Errorf("expected an error (usage error %v), got %v", test.usage, err)
+			}
+		})
+	}
+}
+
+func TestSettingsFlagsFile(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  []string
+		check func(f config.File) bool
+		err   bool
+	}{
+		{name: "nothing set", args: nil,
+			check: func(f config.File) bool { return f.Platform == nil && f.Speed.Tickrate == nil && f.Quirks == nil }},
+		{name: "platform and speed", args: []string{"-platform", "chip48", "-speed", "20"},
+			check: func(f config.File) bool { return *f.Platform == "chip48" && *f.Speed.Tickrate == 20 }},
+		{name: "quirks on and off", args: []string{"-quirks", "shift, -logic"},
+			check: func(f config.File) bool { return len(f.Quirks) == 2 && f.Quirks["shift"] && !f.Quirks["logic"] }},
+		{name: "palette name", args: []string{"-palette", "amber"},
+			check: func(f config.File) bool { return *f.Palette.Name == "amber" && f.Palette.Foreground == nil }},
+		{name: "palette colours", args: []string{"-palette", "#FFB000,#000000"},
+			check: func(f config.File) bool {
+				return *f.Palette.Foreground == "#FFB000" && *f.Palette.Background == "#000000" && f.Palette.Name == nil
+			}},
+		{name: "single colour", args: []string{"-palette", "#FFB000"}, err: true},
+		{name: "layout", args: []string{"-memory", "65536", "-load", "0x600"},
+			check: func(f config.File) bool { return *f.Layout.MemorySize == 65536 && *f.Layout.LoadAddress == 0x600 }},
+		{name: "window", args: []string{"-scale", "4", "-scaling", "fit", "-fullscreen"},
+			check: func(f config.File) bool {
+				return *f.Window.Scale == 4 && *f.Window.Scaling == "fit" && *f.Window.Fullscreen
+			}},
+		{name: "display", args: []string{"-persistence", "3", "-effect", "crt", "-font", "vip"},
+			check: func(f config.File) bool {
+				return *f.Display.Persistence == 3 && *f.Display.Effect == "crt" && *f.Font == "vip"
+			}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			var flags settingsFlags
+			flags.register(fs)
+			if err := fs.Parse(test.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			f, err := flags.file(fs)
+			if test.err {
+				var uerr usageError
+				if !errors.As(err, &uerr) {
+					t.Errorf("expected a usage error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.check(f) {
+				t.Errorf("unexpected config from %v: %+v", test.args, f)
+			}
+		})
+	}
+}
+
+func TestRunCLIExitCodes(t *testing.T) {
+	isolateConfig(t)
+	faulty := filepath.Join(t.TempDir(), "faulty.ch8")
+	if err := os.WriteFile(faulty, []byte{0x00, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	overflow := filepath.Join(t.TempDir(), "overflow.ch8")
+	if err := os.WriteFile(overflow, []byte{0x22, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "help", args: []string{"help"}, code: 0},
+		{name: "info", args: []string{"info", "roms/ibm_logo.ch8"}, code: 0},
+		{name: "disasm", args: []string{"disasm", "-load", "0x600", "roms/ibm_logo.ch8"}, code: 0},
+		{name: "test", args: []string{"test", "-frames", "5", "roms/ibm_logo.ch8"}, code: 0},
+		{name: "flag before the command", args: []string{"-speed", "20", "info"}, code: 2},
+		{name: "no rom", args: []string{"info"}, code: 2},
+		{name: "two roms", args: []string{"test", "a.ch8", "b.ch8"}, code: 2},
+		{name: "unknown flag", args: []string{"disasm", "-x", "roms/ibm_logo.ch8"}, code: 2},
+		{name: "bad palette", args: []string{"info", "-palette", "#FFB000", "roms/ibm_logo.ch8"}, code: 2},
+		{name: "replay without a rom", args: []string{"replay", "run.json"}, code: 2},
+		{name: "too many fonts", args: []string{"fonts", "vip", "octo"}, code: 2},
+		{name: "missing rom", args: []string{"info", "missing.ch8"}, code: 1},
+		{name: "setting out of range", args: []string{"test", "-speed", "0", "roms/ibm_logo.ch8"}, code: 1},
+		{name: "unknown instruction", args: []string{"test", "-frames", "5", faulty}, code: 1},
+		{name: "stack overflow", args: []string{"test", "-frames", "5", overflow}, code: 1},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			err := runCLI(test.args, strings.NewReader(""), io.Discard)
+			if got := exitCode(err); got != test.code {
+				t.Errorf("expected exit code %d, got %d (%v)", test.code, got, err)
+			}
+		})
+	}
+}
+
+func TestKeymapSaveAndLoad(t *testing.T) {
+	keymap, err := parseKeymap(map[byte][]string{0x4: {"Q", "Pad:A"}, 0x5: {"W"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Clearing a key in the rebinder keeps it with no bindings
+	keymap[0x5] = nil
+
+	names := keymap.names()
+	if got, ok := names[0x5]; !ok || got == nil || len(got) != 0 {
+		t.Fatalf("expected key 5 as an empty list, got %#v", got)
+	}
+
+	base := filepath.Join(t.TempDir(), "keys")
+	path, err := config.SaveKeymap(base, names)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := config.Defaults()
+	f, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("saved file does not load: %v", err)
+	}
+	if err := s.Apply(path, f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := parseKeymap(s.Keymap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bindings, ok := loaded[0x5]; !ok || len(bindings) != 0 {
+		t.Errorf("expected key 5 to stay cleared, got %v", bindings)
+	}
+	if len(loaded[0x4]) != 2 || loaded[0x4][1].name != "Pad:A" {
+		t.Errorf("expected key 4 to keep Q and Pad:A, got %v", loaded[0x4])
+	}
+}
+
+func TestParseBinding(t *testing.T) {
+	cases := []struct {
+		name string
+		kind bindingKind
+		err  bool
+	}{
+		{name: "Q", kind: keyBinding},
+		{name: "ArrowUp", kind: keyBinding},
+		{name: "Space", kind: keyBinding},
+		{name: "Pad:A", kind: buttonBinding},
+		{name: "Pad:Start", kind: buttonBinding},
+		{name: "Pad:LeftY-", kind: axisBinding},
+		{name: "Pad:RightX+", kind: axisBinding},
+		{name: "", err: true},
+		{name: "Arrow", err: true},
+		{name: "q ", err: true},
+		{name: "Pad:", err: true},
+		{name: "Pad:Z", err: true},
+		{name: "Pad:LeftY", err: true},
+		{name: "pad:A", err: true},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := parseBinding(test.name)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected an error, got a binding of kind %d", b.kind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.kind != test.kind || b.name != test.name {
+				t.Errorf("expected %s of kind %d, got %s of kind %d", test.name, test.kind, b.name, b.kind)
+			}
+		})
+	}
+}
+
+func TestParseKeymap(t *testing.T) {
+	keymap, err := parseKeymap(config.DefaultKeymap)
+	if err != nil {
+		t.Fatalf("default keymap: unexpected error: %v", err)
+	}
+	if len(keymap) != 16 {
+		t.Errorf("expected all 16 keys, got %d", len(keymap))
+	}
+
+	cases := []struct {
+		name  string
+		names map[byte][]string
+		want  string // in the error
+	}{
+		{name: "bad key name", names: map[byte][]string{0x5: {"W", "Wubble"}}, want: `keymap.5: unknown key "Wubble"`},
+		{name: "bad gamepad name", names: map[byte][]string{0xA: {"Pad:Turbo"}}, want: `keymap.A: unknown gamepad input "Pad:Turbo"`},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseKeymap(test.names)
+			if err == nil || err.Error() != test.want {
+				t.Errorf("expected error %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestDisplayRect(t *testing.T) {
+	cases := []struct {
+		name               string
+		screen_w, screen_h int
+		width, height      int
+		integer            bool
+		want               image.Rectangle
+	}{
+		{name: "exact fit", screen_w: 640, screen_h: 320, width: 64, height: 32, integer: true, want: image.Rect(0, 0, 640, 320)},
+		{name: "integer leaves bars on all sides", screen_w: 800, screen_h: 600, width: 64, height: 32, integer: true, want: image.Rect(16, 108, 784, 492)},
+		{name: "fit fills the width", screen_w: 800, screen_h: 600, width: 64, height: 32, want: image.Rect(0, 100, 800, 500)},
+		{name: "fit fills the height", screen_w: 1000, screen_h: 300, width: 64, height: 32, want: image.Rect(200, 0, 800, 300)},
+		{name: "tall window", screen_w: 320, screen_h: 1000, width: 64, height: 32, integer: true, want: image.Rect(0, 420, 320, 580)},
+		{name: "extended mode", screen_w: 800, screen_h: 600, width: 128, height: 64, integer: true, want: image.Rect(16, 108, 784, 492)},
+		{name: "integer never goes below 1x", screen_w: 40, screen_h: 20, width: 64, height: 32, integer: true, want: image.Rect(-12, -6, 52, 26)},
+		{name: "fit shrinks a small window", screen_w: 40, screen_h: 20, width: 64, height: 32, want: image.Rect(0, 0, 40, 20)},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got := displayRect(test.screen_w, test.screen_h, test.width, test.height, test.integer)
+			if got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
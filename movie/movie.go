@@ -0,0 +1,231 @@
+// Package movie records the keypad state of every frame of a run so the run can be
+// replayed exactly, for bug reports and regression tests. A movie only replays
+// correctly on an emulator stepped with Chip8.RunFrame, which does not depend on the
+// host clock.
+package movie
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/romdb"
+)
+
+// FormatVersion is the version of the movie file layout. Version 2 added the timers,
+// the stack depth and the display size to the checksum.
+const FormatVersion = 2
+
+// ErrDesync is returned when a replay does not end in the recorded state
+var ErrDesync = errors.New("replay desynced")
+
+// Movie is a recorded run: everything needed to start the emulator the same way,
+// the keys held on each frame and a checksum of the state at the end
+type Movie struct {
+	Version  int           `json:"version"`
+	Emulator string        `json:"emulator"` // chip8.Version that recorded the movie
+	ROMSHA1  string        `json:"romSHA1"`
+	Quirks   chip8.Quirks  `json:"quirks"`
+	Font     *chip8.Font   `json:"font,omitempty"`   // the default font when not set
+	Layout   *chip8.Layout `json:"layout,omitempty"` // chip8.DefaultLayout when not set
+	Tickrate int           `json:"tickrate"`         // instructions per frame
+	Seed     uint64        `json:"seed"`
+	Frames   int           `json:"frames"`
+	Input    []Span        `json:"input"`
+	Checksum string        `json:"checksum"`
+}
+
+// Span is a number of frames in a row with the same keys held. Keys has bit n set
+// when key n is held.
+type Span struct {
+	Frames int    `json:"frames"`
+	Keys   uint16 `json:"keys"`
+}
+
+// Load reads a movie file
+func Load(path string) (*Movie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Movie
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Version != FormatVersion {
+		return nil, fmt.Errorf("%s: unsupported movie version %d", path, m.Version)
+	}
+	return &m, nil
+}
+
+// Save writes the movie to path
+func (m *Movie) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// NewEmulator creates an emulator for rom set up the way it was when the movie was
+// recorded. It fails if rom is not the ROM the movie was recorded with.
+func (m *Movie) NewEmulator(rom []byte) (*chip8.Chip8, error) {
+	if hash := romdb.Hash(rom); hash != m.ROMSHA1 {
+		return nil, fmt.Errorf("movie was recorded with ROM %s, got %s", m.ROMSHA1, hash)
+	}
+	opts := []chip8.Option{chip8.WithQuirks(m.Quirks), chip8.WithSeed(m.Seed)}
+	if m.Font != nil {
+		opts = append(opts, chip8.WithFont(*m.Font))
+	}
+	if m.Layout != nil {
+		opts = append(opts, chip8.WithLayout(*m.Layout))
+	}
+	return chip8.New(rom, opts...)
+}
+
+// Verify compares the state of emu after the last frame with the recorded checksum
+func (m *Movie) Verify(emu *chip8.Chip8) error {
+	got := Checksum(emu)
+	if got == m.Checksum {
+		return nil
+	}
+	err := fmt.Errorf("%w: checksum is %s, recorded %s", ErrDesync, got, m.Checksum)
+	if m.Emulator != chip8.Version {
+		err = fmt.Errorf("%w (recorded on version %s, this is %s)", err, m.Emulator, chip8.Version)
+	}
+	return err
+}
+
+// Replay runs the whole movie without a frontend and verifies the end state
+func Replay(m *Movie, rom []byte) (*chip8.Chip8, error) {
+	emu, err := m.NewEmulator(rom)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPlayer(m)
+	for {
+		keys, ok := p.Next()
+		if !ok {
+			break
+		}
+		emu.SetKeysPressed(keys)
+		if err := emu.RunFrame(m.Tickrate); err != nil {
+			return emu, fmt.Errorf("frame %d: %w", p.frame, err)
+		}
+	}
+	return emu, m.Verify(emu)
+}
+
+// Checksum returns a SHA-1 of the memory, the display, the registers, the stack and
+// the timers
+func Checksum(emu *chip8.Chip8) string {
+	h := sha1.New()
+	h.Write(emu.Memory[:])
+	h.Write(emu.Registers[:])
+	binary.Write(h, binary.BigEndian, emu.PC)
+	binary.Write(h, binary.BigEndian, emu.Index)
+	binary.Write(h, binary.BigEndian, emu.Stack)
+	binary.Write(h, binary.BigEndian, uint8(emu.StackDepth()))
+	delay, sound := emu.Timers()
+	h.Write([]byte{delay, sound})
+	width, height := emu.Display.Width(), emu.Display.Height()
+	binary.Write(h, binary.BigEndian, [2]uint16{uint16(width), uint16(height)})
+	row := make([]byte, width)
+	for y := range height {
+		for x := range width {
+			row[x] = emu.Display.Pixel(x, y)
+		}
+		h.Write(row)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Recorder builds a movie one frame at a time
+type Recorder struct {
+	movie Movie
+}
+
+// NewRecorder starts a movie. The emulator being recorded must be new and have been
+// given the same quirks and seed.
+func NewRecorder(rom []byte, quirks chip8.Quirks, tickrate int, seed uint64) *Recorder {
+	return &Recorder{movie: Movie{
+		Version:  FormatVersion,
+		Emulator: chip8.Version,
+		ROMSHA1:  romdb.Hash(rom),
+		Quirks:   quirks,
+		Tickrate: tickrate,
+		Seed:     seed,
+	}}
+}
+
+// Frame records the keys held for the next frame, call it before every RunFrame
+func (r *Recorder) Frame(keys []byte) {
+	mask := keyMask(keys)
+	r.movie.Frames++
+	if n := len(r.movie.Input); n > 0 && r.movie.Input[n-1].Keys == mask {
+		r.movie.Input[n-1].Frames++
+		return
+	}
+	r.movie.Input = append(r.movie.Input, Span{Frames: 1, Keys: mask})
+}
+
+// Finish ends the recording and returns the movie with the checksum of emu
+func (r *Recorder) Finish(emu *chip8.Chip8) *Movie {
+	m := r.movie
+	m.Input = append([]Span(nil), r.movie.Input...)
+	m.Checksum = Checksum(emu)
+	font := emu.Font()
+	m.Font = &font
+	layout := emu.Layout()
+	m.Layout = &layout
+	return &m
+}
+
+// Player hands out the recorded keys one frame at a time
+type Player struct {
+	movie *Movie
+	span  int // index into movie.Input
+	used  int // frames of the current span already played
+	frame int // frames played
+}
+
+func NewPlayer(m *Movie) *Player {
+	return &Player{movie: m}
+}
+
+// Next returns the keys for the next frame, or false once the movie has ended
+func (p *Player) Next() ([]byte, bool) {
+	for p.span < len(p.movie.Input) && p.used >= p.movie.Input[p.span].Frames {
+		p.span++
+		p.used = 0
+	}
+	if p.span >= len(p.movie.Input) {
+		return nil, false
+	}
+	p.used++
+	p.frame++
+	return maskKeys(p.movie.Input[p.span].Keys), true
+}
+
+func keyMask(keys []byte) uint16 {
+	var mask uint16
+	for _, k := range keys {
+		mask |= 1 << (k & 0x0F)
+	}
+	return mask
+}
+
+func maskKeys(mask uint16) []byte {
+	var keys []byte
+	for k := range 16 {
+		if mask&(1<<k) != 0 {
+			keys = append(keys, byte(k))
+		}
+	}
+	return keys
+}
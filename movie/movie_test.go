@@ -0,0 +1,166 @@
+package movie
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+// testRom adds a random number to V2 every loop and counts the loops where key 0 is
+// held in V3, so both the seed and the input change the end state
+var testRom = []byte{
+	0xC0, 0xFF, // LD V0, random
+	0x82, 0x04, // ADD V2, V0
+	0xE1, 0x9E, // SKP V1
+	0x12, 0x00, // JP 0x200
+	0x73, 0x01, // ADD V3, 1
+	0x12, 0x00, // JP 0x200
+}
+
+func record(t testing.TB, seed uint64, input [][]byte) *Movie {
+	emu, err := chip8.NewChip8FromByte(testRom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu.Seed(seed)
+	r := NewRecorder(testRom, emu.Quirks, 10, seed)
+	for _, keys := range input {
+		r.Frame(keys)
+		emu.SetKeysPressed(keys)
+		if err := emu.RunFrame(10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r.Finish(emu)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	input := [][]byte{nil, nil, {0x0}, {0x0}, {0x0, 0x5}, nil, {0x5}}
+	m := record(t, 42, input)
+
+	if m.Frames != len(input) {
+		t.Errorf("expected %d frames, got %d", len(input), m.Frames)
+	}
+	wantSpans := []Span{{2, 0x0000}, {2, 0x0001}, {1, 0x0021}, {1, 0x0000}, {1, 0x0020}}
+	if len(m.Input) != len(wantSpans) {
+		t.Fatalf("expected spans %v, got %v", wantSpans, m.Input)
+	}
+	for i := range wantSpans {
+		if m.Input[i] != wantSpans[i] {
+			t.Errorf("span %d: expected %v, got %v", i, wantSpans[i], m.Input[i])
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu, err := Replay(loaded, testRom)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if emu.Registers[3] == 0 {
+		t.Errorf("expected the held key to be counted in V3")
+	}
+}
+
+func TestReplayDetectsDesync(t *testing.T) {
+	input := [][]byte{nil, {0x0}, {0x0}, nil}
+
+	t.Run("different input", func(t *testing.T) {
+		m := record(t, 7, input)
+		m.Input[1].Frames = 1
+		if _, err := Replay(m, testRom); !errors.Is(err, ErrDesync) {
+			t.Errorf("expected ErrDesync, got %v", err)
+		}
+	})
+
+	t.Run("different seed", func(t *testing.T) {
+		m := record(t, 7, input)
+		m.Seed = 8
+		if _, err := Replay(m, testRom); !errors.Is(err, ErrDesync) {
+			t.Errorf("expected ErrDesync, got %v", err)
+		}
+	})
+
+	t.Run("different rom", func(t *testing.T) {
+		m := record(t, 7, input)
+		other := append([]byte{0x00, 0xE0}, testRom...)
+		if _, err := Replay(m, other); err == nil || errors.Is(err, ErrDesync) {
+			t.Errorf("expected a ROM mismatch error, got %v", err)
+		}
+	})
+}
+
+func TestReplayKeepsLayout(t *testing.T) {
+	// testRom jumps to 0x200, so relocate the jumps for a ROM loaded at 0x600
+	rom := append([]byte(nil), testRom...)
+	rom[6], rom[10] = 0x16, 0x16
+	layout := chip8.Layout{MemorySize: 4096, FontAddress: 0x50, LoadAddress: 0x600, StartPC: 0x600}
+	emu, err := chip8.New(rom, chip8.WithLayout(layout), chip8.WithSeed(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder(rom, emu.Quirks, 10, 3)
+	for _, keys := range [][]byte{nil, {0x0}, nil} {
+		r.Frame(keys)
+		emu.SetKeysPressed(keys)
+		if err := emu.RunFrame(10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := r.Finish(emu)
+
+	got, err := Replay(m, rom)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if got.Layout() != layout {
+		t.Errorf("expected layout %+v, got %+v", layout, got.Layout())
+	}
+
+	m.Layout = nil
+	if _, err := Replay(m, rom); err == nil {
+		t.Errorf("expected the replay to fail without the layout")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	// LD V0, 5; LD DT, V0; JP 0x204
+	emu, err := chip8.NewChip8FromByte([]byte{0x60, 0x05, 0xF0, 0x15, 0x12, 0x04})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := emu.RunFrame(3); err != nil {
+		t.Fatal(err)
+	}
+	before := Checksum(emu)
+
+	t.Run("timers", func(t *testing.T) {
+		ticked := emu.Clone()
+		if err := ticked.RunFrame(1); err != nil {
+			t.Fatal(err)
+		}
+		if ticked.PC != emu.PC || Checksum(ticked) == before {
+			t.Errorf("expected the delay timer to change the checksum")
+		}
+	})
+
+	t.Run("display size", func(t *testing.T) {
+		hires := emu.Clone()
+		hires.Display = chip8.NewFramebuffer(128, 64, 1)
+		if Checksum(hires) == before {
+			t.Errorf("expected a blank 128x64 display to change the checksum")
+		}
+	})
+
+	if Checksum(emu.Clone()) != before {
+		t.Errorf("expected a copy to have the same checksum")
+	}
+}
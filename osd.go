@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/tomanta/echip8/host"
+)
+
+const (
+	messageFrames = 2 * host.FrameRate // host frames a message stays on screen
+	charWidth     = 6                  // width of the ebitenutil debug font
+)
+
+var (
+	osdBackground   = color.RGBA{0x00, 0x00, 0x00, 0xB0}
+	faultBackground = color.RGBA{0xA0, 0x00, 0x00, 0xE0}
+)
+
+// osd is the on-screen display drawn over a game: short messages from the
+// hotkeys, the statistics overlay and a banner when the emulator fails
+type osd struct {
+	message string
+	left    int    // host frames until the message is hidden
+	fault   string // the emulator's error, shown until the game is resumed
+	stats   bool
+
+	// The rates in the overlay are measured over a second of wall time
+	sampled      time.Time
+	frames       int // the runner's counters when sampled
+	instructions int
+	fps, ips     float64
+}
+
+// show puts a message on screen, replacing the previous one
+func (o *osd) show(format string, args ...any) {
+	o.message = fmt.Sprintf(format, args...)
+	o.left = messageFrames
+}
+
+// fail shows err in the error banner
+func (o *osd) fail(err error) {
+	o.fault = err.Error()
+}
+
+// update ages the message and measures the rates, it is called once per host frame
+func (o *osd) update(r *host.Runner) {
+	if o.left > 0 {
+		o.left--
+	}
+	now := time.Now()
+	if o.sampled.IsZero() {
+		o.sampled, o.frames, o.instructions = now, r.Frames(), r.Instructions()
+		return
+	}
+	elapsed := now.Sub(o.sampled).Seconds()
+	if elapsed < 1 {
+		return
+	}
+	o.fps = (float64)(r.Frames()-o.frames) / elapsed
+	o.ips = (float64)(r.Instructions()-o.instructions) / elapsed
+	o.sampled, o.frames, o.instructions = now, r.Frames(), r.Instructions()
+}
+
+// draw draws the banner at the top, the statistics at the top left under it and
+// the message at the bottom left
+func (o *osd) draw(screen *ebiten.Image, g *Game) {
+	y := 0
+	if o.fault != "" {
+		vector.DrawFilledRect(screen, 0, 0, (float32)(screen.Bounds().Dx()), launcherLineHeight, faultBackground, false)
+		ebitenutil.DebugPrintAt(screen, "Error: "+o.fault+" (F4 to continue, Esc for the menu)", 4, 0)
+		y += launcherLineHeight
+	}
+	if o.stats {
+		for _, line := range o.statsLines(g) {
+			drawLabel(screen, line, 0, y)
+			y += launcherLineHeight
+		}
+	}
+	if o.left > 0 {
+		drawLabel(screen, o.message, 0, screen.Bounds().Dy()-launcherLineHeight)
+	}
+}
+
+// statsLines returns the text of the statistics overlay
+func (o *osd) statsLines(g *Game) []string {
+	r := g.runner
+	delay, sound := g.emu.Timers()
+	state := "running"
+	switch {
+	case r.Paused():
+		state = "paused"
+	case g.emu.WaitingForKey():
+		state = "waiting for a key (FX0A)"
+	case g.emu.WaitingForDisplay():
+		state = "waiting for the next frame (DXYN)"
+	}
+	return []string{
+		fmt.Sprintf("IPS %.0f  FPS %.1f  draw %.1f", o.ips, o.fps, ebiten.ActualFPS()),
+		fmt.Sprintf("IPF %d  speed %s", r.IPF(), speedName(r)),
+		fmt.Sprintf("DT %3d  ST %3d", delay, sound),
+		state,
+	}
+}
+
+// drawLabel draws text on a dark box so it can be read on any palette
+func drawLabel(screen *ebiten.Image, text string, x, y int) {
+	width := len(text)*charWidth + 8
+	vector.DrawFilledRect(screen, (float32)(x), (float32)(y), (float32)(width), launcherLineHeight, osdBackground, false)
+	ebitenutil.DebugPrintAt(screen, text, x+4, y)
+}
+
+// speedName describes the speed of the runner, like "4x" or "uncapped"
+func speedName(r *host.Runner) string {
+	if r.Uncapped() {
+		return "uncapped"
+	}
+	return strconv.FormatFloat(r.Speed(), 'g', -1, 64) + "x"
+}
@@ -0,0 +1,86 @@
+// Package palette holds the colour schemes for the display. A palette has four
+// colours so it can also colour the two bitplanes of XO-CHIP: the background,
+// pixels only in plane 1, pixels only in plane 2 and pixels in both planes. Plain
+// CHIP-8 only uses the first two.
+package palette
+
+import "image/color"
+
+// Palette is a named set of display colours
+type Palette struct {
+	Name   string
+	Colors [4]color.RGBA // background, plane 1, plane 2, both planes
+}
+
+// Background is the colour of unlit pixels
+func (p Palette) Background() color.RGBA {
+	return p.Colors[0]
+}
+
+// Foreground is the colour of lit pixels on a single plane display
+func (p Palette) Foreground() color.RGBA {
+	return p.Colors[1]
+}
+
+// Pixel returns the colour of a pixel lit in the planes set in planes, bit n for
+// plane n as returned by chip8.Framebuffer.Pixel. Only the first two planes have
+// colours of their own, a pixel lit only in higher planes gets the foreground.
+func (p Palette) Pixel(planes uint8) color.RGBA {
+	if planes != 0 && planes&3 == 0 {
+		return p.Foreground()
+	}
+	return p.Colors[planes&3]
+}
+
+// Custom is the name given to palettes changed from a preset
+const Custom = "custom"
+
+// Presets are the built in palettes, the first is the default
+var Presets = []Palette{
+	{"green", [4]color.RGBA{rgb(0x000000), rgb(0x33FF33), rgb(0x0F7F0F), rgb(0xAAFFAA)}},
+	{"amber", [4]color.RGBA{rgb(0x000000), rgb(0xFFB000), rgb(0x7F5800), rgb(0xFFE0A0)}},
+	{"white", [4]color.RGBA{rgb(0x000000), rgb(0xFFFFFF), rgb(0x808080), rgb(0xC0C0C0)}},
+	{"lcd", [4]color.RGBA{rgb(0x9BBC0F), rgb(0x0F380F), rgb(0x8BAC0F), rgb(0x306230)}},
+	{"contrast", [4]color.RGBA{rgb(0x000000), rgb(0xFFFFFF), rgb(0xFFFF00), rgb(0x00FFFF)}},
+	{"octo", [4]color.RGBA{rgb(0x996600), rgb(0xFFCC00), rgb(0xFF6600), rgb(0x662200)}},
+}
+
+// Default returns the first preset
+func Default() Palette {
+	return Presets[0]
+}
+
+// Lookup returns the preset called name
+func Lookup(name string) (Palette, bool) {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Palette{}, false
+}
+
+// Names returns the names of the presets in order
+func Names() []string {
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Cycle returns the palettes to switch between at runtime: start followed by the
+// presets, leaving out the preset start already is
+func Cycle(start Palette) []Palette {
+	palettes := []Palette{start}
+	for _, p := range Presets {
+		if p != start {
+			palettes = append(palettes, p)
+		}
+	}
+	return palettes
+}
+
+func rgb(hex uint32) color.RGBA {
+	return color.RGBA{uint8(hex >> 16), uint8(hex >> 8), uint8(hex), 0xFF}
+}
@@ -0,0 +1,49 @@
+package palette
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	p, ok := Lookup("amber")
+	if !ok {
+		t.Fatal("expected the amber preset")
+	}
+	if p.Foreground() != (color.RGBA{0xFF, 0xB0, 0x00, 0xFF}) || p.Background() != (color.RGBA{0, 0, 0, 0xFF}) {
+		t.Errorf("unexpected amber colours %v", p.Colors)
+	}
+	if _, ok := Lookup("purple"); ok {
+		t.Error("expected no purple preset")
+	}
+}
+
+func TestCycle(t *testing.T) {
+	amber, _ := Lookup("amber")
+	if got := Cycle(amber); len(got) != len(Presets) || got[0] != amber {
+		t.Errorf("expected amber first and every preset once, got %v", got)
+	}
+
+	custom := amber
+	custom.Name = Custom
+	custom.Colors[1] = color.RGBA{1, 2, 3, 0xFF}
+	if got := Cycle(custom); len(got) != len(Presets)+1 || got[0] != custom {
+		t.Errorf("expected the custom palette followed by every preset, got %v", got)
+	}
+}
+
+func TestPixel(t *testing.T) {
+	p, _ := Lookup("contrast")
+	for planes, want := range map[uint8]color.RGBA{
+		0b0000: p.Colors[0],
+		0b0001: p.Colors[1],
+		0b0010: p.Colors[2],
+		0b0011: p.Colors[3],
+		0b0100: p.Foreground(),
+		0b0110: p.Colors[2],
+	} {
+		if got := p.Pixel(planes); got != want {
+			t.Errorf("planes %04b: expected %v, got %v", planes, want, got)
+		}
+	}
+}
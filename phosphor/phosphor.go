@@ -0,0 +1,91 @@
+// Package phosphor fades pixels out over a few frames the way the phosphor of a CRT
+// does. CHIP-8 games move sprites by XOR-ing them off and on again, which flickers
+// badly when the raw display is shown; blending each pixel with its recent past
+// hides most of it. The filter only works on pixel states so any frontend, or an
+// image export, can use it.
+package phosphor
+
+import (
+	"image"
+	"image/color"
+)
+
+// Filter keeps an intensity from 0 to 1 for every pixel. Lit pixels are at full
+// intensity, pixels that turn off fade to 0 over the configured number of frames.
+type Filter struct {
+	frames    int
+	width     int
+	height    int
+	intensity []float32 // row major
+}
+
+// New returns a filter that keeps pixels glowing for frames frames after they turn
+// off. With 0 frames the filter shows the display as it is.
+func New(frames int) *Filter {
+	return &Filter{frames: max(frames, 0)}
+}
+
+// Frames returns the number of frames a pixel fades over
+func (f *Filter) Frames() int {
+	return f.frames
+}
+
+// Update adds one emulated frame to the filter, lit reports whether the pixel at
+// x, y is on. Changing the size resets the filter.
+func (f *Filter) Update(width, height int, lit func(x, y int) bool) {
+	f.UpdateFrames(1, width, height, lit)
+}
+
+// UpdateFrames is Update for a frontend that shows the display less often than
+// every emulated frame, such as when fast-forwarding: pixels that are off fade by
+// elapsed frames at once, so a fade lasts the same number of emulated frames at
+// any speed and refresh rate.
+func (f *Filter) UpdateFrames(elapsed, width, height int, lit func(x, y int) bool) {
+	if width != f.width || height != f.height {
+		f.width, f.height = width, height
+		f.intensity = make([]float32, width*height)
+	}
+	step := float32(max(elapsed, 1)) / float32(f.frames+1)
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			if lit(x, y) {
+				f.intensity[i] = 1
+			} else {
+				f.intensity[i] = max(f.intensity[i]-step, 0)
+			}
+		}
+	}
+}
+
+// Intensity returns how brightly the pixel at x, y glows, 0 outside the display
+func (f *Filter) Intensity(x, y int) float32 {
+	if x < 0 || y < 0 || x >= f.width || y >= f.height {
+		return 0
+	}
+	return f.intensity[y*f.width+x]
+}
+
+// Color blends the background and foreground by the intensity of the pixel at x, y
+func (f *Filter) Color(x, y int, fg, bg color.RGBA) color.RGBA {
+	return Blend(fg, bg, f.Intensity(x, y))
+}
+
+// Image returns the filtered display at one image pixel per CHIP-8 pixel
+func (f *Filter) Image(fg, bg color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	for y := range f.height {
+		for x := range f.width {
+			img.SetRGBA(x, y, f.Color(x, y, fg, bg))
+		}
+	}
+	return img
+}
+
+// Blend mixes fg and bg, t is the amount of fg from 0 to 1
+func Blend(fg, bg color.RGBA, t float32) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float32(b) + (float32(a)-float32(b))*t + 0.5)
+	}
+	return color.RGBA{mix(fg.R, bg.R), mix(fg.G, bg.G), mix(fg.B, bg.B), mix(fg.A, bg.A)}
+}
@@ -0,0 +1,82 @@
+package phosphor
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	on := true
+	lit := func(x, y int) bool { return on && x == 1 && y == 0 }
+
+	f := New(3)
+	f.Update(4, 2, lit)
+	if got := f.Intensity(1, 0); got != 1 {
+		t.Errorf("expected a lit pixel at full intensity, got %g", got)
+	}
+	if got := f.Intensity(0, 0); got != 0 {
+		t.Errorf("expected an unlit pixel at 0, got %g", got)
+	}
+
+	on = false
+	want := []float32{0.75, 0.5, 0.25, 0, 0}
+	for frame, w := range want {
+		f.Update(4, 2, lit)
+		if got := f.Intensity(1, 0); got != w {
+			t.Errorf("frame %d after turning off: expected %g, got %g", frame+1, w, got)
+		}
+	}
+}
+
+func TestFilterUpdateFrames(t *testing.T) {
+	on := true
+	lit := func(x, y int) bool { return on }
+	f := New(3)
+	f.UpdateFrames(1, 1, 1, lit)
+
+	// Two emulated frames shown at once fade as far as two single frames
+	on = false
+	f.UpdateFrames(2, 1, 1, lit)
+	if got := f.Intensity(0, 0); got != 0.5 {
+		t.Errorf("expected 0.5 after two frames, got %g", got)
+	}
+	f.UpdateFrames(8, 1, 1, lit)
+	if got := f.Intensity(0, 0); got != 0 {
+		t.Errorf("expected the pixel to be off after ten frames, got %g", got)
+	}
+}
+
+func TestFilterWithoutPersistence(t *testing.T) {
+	on := true
+	lit := func(x, y int) bool { return on }
+	f := New(0)
+	f.Update(2, 2, lit)
+	on = false
+	f.Update(2, 2, lit)
+	if got := f.Intensity(0, 0); got != 0 {
+		t.Errorf("expected pixels to turn off at once, got %g", got)
+	}
+}
+
+func TestImage(t *testing.T) {
+	fg := color.RGBA{255, 255, 255, 255}
+	bg := color.RGBA{0, 0, 0, 255}
+	on := true
+	lit := func(x, y int) bool { return on && x == 0 }
+
+	f := New(1)
+	f.Update(2, 1, lit)
+	on = false
+	f.Update(2, 1, lit)
+
+	img := f.Image(fg, bg)
+	if got := img.Bounds().Size(); got.X != 2 || got.Y != 1 {
+		t.Fatalf("expected a 2x1 image, got %v", got)
+	}
+	if got, want := img.RGBAAt(0, 0), (color.RGBA{128, 128, 128, 255}); got != want {
+		t.Errorf("expected a half faded pixel %v, got %v", want, got)
+	}
+	if got := img.RGBAAt(1, 0); got != bg {
+		t.Errorf("expected the background %v, got %v", bg, got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/tomanta/echip8/config"
+)
+
+// keypadOrder lists the CHIP-8 keys in the order they appear on the keypad
+var keypadOrder = [16]byte{
+	0x1, 0x2, 0x3, 0xC,
+	0x4, 0x5, 0x6, 0xD,
+	0x7, 0x8, 0x9, 0xE,
+	0xA, 0x0, 0xB, 0xF,
+}
+
+// Rebinder is the screen for changing the keymap of the running game
+type Rebinder struct {
+	keymap  Keymap
+	romName string
+	cursor  int  // index into keypadOrder
+	waiting bool // the next input pressed is added to the key under the cursor
+	status  string
+	closed  bool
+}
+
+func newRebinder(keymap Keymap, romName string) *Rebinder {
+	// Work on a copy so the game's keymap only changes when the screen is closed
+	copied := make(Keymap, len(keymap))
+	for key, bindings := range keymap {
+		copied[key] = append([]binding(nil), bindings...)
+	}
+	return &Rebinder{keymap: copied, romName: romName}
+}
+
+func (r *Rebinder) Update() {
+	if r.waiting {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			r.waiting = false
+			r.status = "cancelled"
+			return
+		}
+		if b, ok := justPressedBinding(); ok {
+			key := keypadOrder[r.cursor]
+			r.keymap[key] = append(r.keymap[key], b)
+			r.waiting = false
+			r.status = fmt.Sprintf("added %s to key %X", b.name, key)
+		}
+		return
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		r.closed = true
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		r.cursor = (r.cursor + len(keypadOrder) - 1) % len(keypadOrder)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		r.cursor = (r.cursor + 1) % len(keypadOrder)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		r.waiting = true
+		r.status = fmt.Sprintf("press a key or gamepad input for %X, Esc to cancel", keypadOrder[r.cursor])
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace), inpututil.IsKeyJustPressed(ebiten.KeyDelete):
+		// Kept with no bindings so saving writes the key out and it stays cleared
+		r.keymap[keypadOrder[r.cursor]] = nil
+		r.status = fmt.Sprintf("cleared key %X", keypadOrder[r.cursor])
+	case inpututil.IsKeyJustPressed(ebiten.KeyF5):
+		r.save(func(dir string) string { return config.ROMBase(dir, r.romName) })
+	case inpututil.IsKeyJustPressed(ebiten.KeyF6):
+		r.save(config.GlobalBase)
+	}
+}
+
+// save writes the keymap to the config file returned by base for the user config dir
+func (r *Rebinder) save(base func(dir string) string) {
+	dir, err := config.UserDir()
+	if err != nil {
+		r.status = err.Error()
+		return
+	}
+	path, err := config.SaveKeymap(base(dir), r.keymap.names())
+	if err != nil {
+		r.status = err.Error()
+		return
+	}
+	r.status = "saved to " + path
+}
+
+func (r *Rebinder) Draw(screen *ebiten.Image) {
+	ebitenutil.DebugPrintAt(screen, "Keymap: Enter add, Backspace clear, F5 save for this ROM, F6 save for all, Esc close", 4, 0)
+	for i, key := range keypadOrder {
+		var names []string
+		for _, b := range r.keymap[key] {
+			names = append(names, b.name)
+		}
+		prefix := "  "
+		if i == r.cursor {
+			prefix = "> "
+		}
+		line := fmt.Sprintf("%s%X: %s", prefix, key, strings.Join(names, ", "))
+		ebitenutil.DebugPrintAt(screen, line, 4, (i+1)*launcherLineHeight)
+	}
+	if r.status != "" {
+		ebitenutil.DebugPrintAt(screen, r.status, 4, (len(keypadOrder)+2)*launcherLineHeight)
+	}
+}
@@ -0,0 +1,199 @@
+// Package romdb identifies ROMs by their SHA-1 hash and returns what is known about
+// them: title, authors, the platform they were written for and the settings they
+// need to run properly. The bundled database follows the layout of the community
+// chip-8-database (https://github.com/chip-8/chip-8-database).
+package romdb
+
+import (
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+// DefaultPlatform is used for ROMs that are not in the database
+const DefaultPlatform = "modernChip8"
+
+//go:embed platforms.json
+var platformsJSON []byte
+
+//go:embed programs.json
+var programsJSON []byte
+
+// Platform is a CHIP-8 interpreter variant, the quirks it implements and where it
+// puts things in memory
+type Platform struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name"`
+	DefaultTickrate int          `json:"defaultTickrate"` // instructions per 60 Hz frame
+	Quirks          chip8.Quirks `json:"quirks"`
+	Layout          chip8.Layout `json:"layout"` // chip8.DefaultLayout when not in platforms.json
+}
+
+// Program is a single game or demo, possibly released as several ROMs
+type Program struct {
+	Title       string         `json:"title"`
+	Authors     []string       `json:"authors"`
+	Description string         `json:"description"`
+	ROMs        map[string]ROM `json:"roms"` // keyed by SHA-1
+}
+
+// ROM holds the settings for one specific ROM file. Every field is optional; empty
+// fields fall back to the platform defaults.
+type ROM struct {
+	File      string          `json:"file,omitempty"`
+	Platforms []string        `json:"platforms,omitempty"` // first entry is the preferred platform
+	Tickrate  int             `json:"tickrate,omitempty"`
+	Quirks    map[string]bool `json:"quirks,omitempty"` // overrides the platform quirks
+	Keys      map[string]int  `json:"keys,omitempty"`   // named input ("up", "a", ...) to CHIP-8 key
+	Colors    *Colors         `json:"colors,omitempty"`
+}
+
+// Colors are HTML style hex colours ("#33FF33"). Pixels[0] is the background and
+// Pixels[1] the foreground; more entries are used for extra bitplanes.
+type Colors struct {
+	Pixels  []string `json:"pixels,omitempty"`
+	Buzzer  string   `json:"buzzer,omitempty"`
+	Silence string   `json:"silence,omitempty"`
+}
+
+// Metadata is everything known about a ROM after combining its database entry with
+// its platform defaults
+type Metadata struct {
+	SHA1     string
+	Known    bool // true if the ROM was found in the database
+	Title    string
+	Authors  []string
+	Platform string
+	Tickrate int
+	Quirks   chip8.Quirks
+	Layout   chip8.Layout
+	Keys     map[string]int
+	Colors   Colors
+}
+
+var (
+	platforms = map[string]Platform{}
+	roms      = map[string]entry{}
+)
+
+type entry struct {
+	program *Program
+	rom     ROM
+}
+
+func init() {
+	var ps []Platform
+	if err := json.Unmarshal(platformsJSON, &ps); err != nil {
+		panic(fmt.Sprintf("romdb: bad platforms.json: %v", err))
+	}
+	for _, p := range ps {
+		if p.Layout == (chip8.Layout{}) {
+			p.Layout = chip8.DefaultLayout
+		}
+		platforms[p.ID] = p
+	}
+
+	var programs []Program
+	if err := json.Unmarshal(programsJSON, &programs); err != nil {
+		panic(fmt.Sprintf("romdb: bad programs.json: %v", err))
+	}
+	for i := range programs {
+		for hash, rom := range programs[i].ROMs {
+			roms[hash] = entry{program: &programs[i], rom: rom}
+		}
+	}
+}
+
+// Hash returns the lowercase hex SHA-1 of the ROM data, the key used by the database
+func Hash(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupPlatform returns the platform with the given id
+func LookupPlatform(id string) (Platform, bool) {
+	p, ok := platforms[id]
+	return p, ok
+}
+
+// Lookup returns the metadata for the ROM data. ROMs not in the database get the
+// defaults for DefaultPlatform and Known set to false.
+func Lookup(rom []byte) Metadata {
+	m, err := resolve(Hash(rom))
+	if err != nil {
+		// The bundled database is checked by the tests so this is a programming error
+		panic(fmt.Sprintf("romdb: %v", err))
+	}
+	return m
+}
+
+// resolve layers the database entry for hash on top of its platform defaults
+func resolve(hash string) (Metadata, error) {
+	m := Metadata{SHA1: hash, Platform: DefaultPlatform}
+	e, known := roms[hash]
+	if known && len(e.rom.Platforms) > 0 {
+		m.Platform = e.rom.Platforms[0]
+	}
+
+	p, ok := platforms[m.Platform]
+	if !ok {
+		return Metadata{}, fmt.Errorf("unknown platform %q", m.Platform)
+	}
+	m.Tickrate = p.DefaultTickrate
+	m.Quirks = p.Quirks
+	m.Layout = p.Layout
+	if !known {
+		return m, nil
+	}
+
+	m.Known = true
+	m.Title = e.program.Title
+	m.Authors = e.program.Authors
+	if e.rom.Tickrate > 0 {
+		m.Tickrate = e.rom.Tickrate
+	}
+	if err := applyQuirks(&m.Quirks, e.rom.Quirks); err != nil {
+		return Metadata{}, err
+	}
+	m.Keys = e.rom.Keys
+	if e.rom.Colors != nil {
+		m.Colors = *e.rom.Colors
+	}
+	return m, nil
+}
+
+// applyQuirks sets the named quirks on q
+func applyQuirks(q *chip8.Quirks, overrides map[string]bool) error {
+	for name, on := range overrides {
+		if err := q.Set(name, on); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseColor converts an HTML style hex colour ("#RRGGBB" or "#RGB") to an opaque RGBA
+func ParseColor(s string) (color.RGBA, error) {
+	c := color.RGBA{A: 0xFF}
+	var err error
+	switch len(s) {
+	case 7:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 4:
+		_, err = fmt.Sscanf(s, "#%1x%1x%1x", &c.R, &c.G, &c.B)
+		c.R *= 0x11
+		c.G *= 0x11
+		c.B *= 0x11
+	default:
+		err = fmt.Errorf("wrong length")
+	}
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q: %w", s, err)
+	}
+	return c, nil
+}
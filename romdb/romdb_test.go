@@ -0,0 +1,125 @@
+package romdb
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+func openTestRom(t testing.TB, name string) []byte {
+	t.Helper()
+	path := filepath.Join("..", "roms", name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not open test rom '%s', received error: %v", path, err)
+	}
+	return data
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("known rom gets title and platform quirks", func(t *testing.T) {
+		got := Lookup(openTestRom(t, "ibm_logo.ch8"))
+		if !got.Known {
+			t.Fatalf("expected ibm_logo.ch8 to be in the database")
+		}
+		if got.Title != "IBM Logo" {
+			t.Errorf("expected title 'IBM Logo', got '%s'", got.Title)
+		}
+		want, _ := LookupPlatform("originalChip8")
+		if got.Quirks != want.Quirks {
+			t.Errorf("expected quirks %+v, got %+v", want.Quirks, got.Quirks)
+		}
+		if got.Tickrate != want.DefaultTickrate {
+			t.Errorf("expected tickrate %d, got %d", want.DefaultTickrate, got.Tickrate)
+		}
+	})
+
+	t.Run("unknown rom gets default platform", func(t *testing.T) {
+		got := Lookup([]byte{0x12, 0x00})
+		if got.Known {
+			t.Errorf("expected rom to be unknown")
+		}
+		if got.Platform != DefaultPlatform {
+			t.Errorf("expected platform %s, got %s", DefaultPlatform, got.Platform)
+		}
+		if got.Quirks != (chip8.Quirks{}) {
+			t.Errorf("expected no quirks, got %+v", got.Quirks)
+		}
+	})
+
+	t.Run("xo-chip wraps sprites", func(t *testing.T) {
+		for _, id := range []string{"xochip", "superchip", "originalChip8"} {
+			p, _ := LookupPlatform(id)
+			if p.Quirks.Wrap != (id == "xochip") {
+				t.Errorf("platform %s: expected wrap %v, got %v", id, id == "xochip", p.Quirks.Wrap)
+			}
+		}
+	})
+
+	t.Run("platform memory layouts", func(t *testing.T) {
+		want := map[string]chip8.Layout{
+			"originalChip8": chip8.DefaultLayout,
+			"eti660":        {MemorySize: 4096, FontAddress: 0x50, LoadAddress: 0x600, StartPC: 0x600},
+			"xochip":        {MemorySize: 0x10000, FontAddress: 0x50, LoadAddress: 0x200, StartPC: 0x200},
+		}
+		for id, layout := range want {
+			p, _ := LookupPlatform(id)
+			if p.Layout != layout {
+				t.Errorf("platform %s: expected layout %+v, got %+v", id, layout, p.Layout)
+			}
+		}
+	})
+
+	t.Run("every rom in the database has a known platform", func(t *testing.T) {
+		for hash, e := range roms {
+			for _, p := range e.rom.Platforms {
+				if _, ok := LookupPlatform(p); !ok {
+					t.Errorf("rom %s (%s) has unknown platform %s", hash, e.rom.File, p)
+				}
+			}
+		}
+	})
+}
+
+func TestResolve(t *testing.T) {
+	t.Run("every rom in the database resolves", func(t *testing.T) {
+		for hash, e := range roms {
+			if _, err := resolve(hash); err != nil {
+				t.Errorf("rom %s (%s): %v", hash, e.rom.File, err)
+			}
+		}
+	})
+}
+
+func TestParseColor(t *testing.T) {
+	cases := []struct {
+		in   string
+		want color.RGBA
+		err  bool
+	}{
+		{in: "#33FF33", want: color.RGBA{0x33, 0xFF, 0x33, 0xFF}},
+		{in: "#fa0", want: color.RGBA{0xFF, 0xAA, 0x00, 0xFF}},
+		{in: "33FF33", err: true},
+		{in: "#GGGGGG", err: true},
+	}
+	for _, test := range cases {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := ParseColor(test.in)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error, did not receive one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
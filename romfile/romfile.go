@@ -0,0 +1,170 @@
+// Package romfile reads ROMs given on the command line. A ROM can be a path to a
+// file, "-" for standard input, or a zip archive. Inside an archive the ROM is
+// named like a path ("games.zip/breakout.ch8"); an archive holding a single ROM
+// can be given on its own ("breakout.zip").
+package romfile
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Dir is searched for bare ROM names that do not exist in the working directory
+const Dir = "roms"
+
+// Stdin is the name used on the command line for standard input
+const Stdin = "-"
+
+// Extensions are the file extensions treated as ROMs inside zip archives
+var Extensions = []string{".ch8", ".c8", ".sc8", ".xo8", ".8o"}
+
+// ROM is a loaded ROM image
+type ROM struct {
+	Name string // file name of the ROM, "stdin" when read from standard input
+	Path string // file the ROM was read from, empty for standard input and archives
+	Data []byte
+}
+
+// Load reads the ROM named by arg. stdin is used when arg is Stdin.
+func Load(arg string, stdin io.Reader) (ROM, error) {
+	if arg == Stdin {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return ROM{}, fmt.Errorf("read rom from stdin: %w", err)
+		}
+		return ROM{Name: "stdin", Data: data}, nil
+	}
+
+	if archive, inner, ok := splitArchive(arg); ok {
+		return loadFromZip(archive, inner)
+	}
+
+	p := arg
+	if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) && !strings.ContainsAny(arg, `/\`) {
+		// Bare names like "breakout.ch8" also work from the roms directory
+		if _, err := os.Stat(filepath.Join(Dir, arg)); err == nil {
+			p = filepath.Join(Dir, arg)
+		}
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return ROM{}, fmt.Errorf("open rom: %w", err)
+	}
+	return ROM{Name: filepath.Base(p), Path: p, Data: data}, nil
+}
+
+// splitArchive splits "games.zip/breakout.ch8" into the archive and the file inside
+// it. inner is empty when arg is just the archive.
+func splitArchive(arg string) (archive, inner string, ok bool) {
+	lower := strings.ToLower(filepath.ToSlash(arg))
+	if strings.HasSuffix(lower, ".zip") {
+		return arg, "", true
+	}
+	if i := strings.LastIndex(lower, ".zip/"); i >= 0 {
+		return arg[:i+len(".zip")], filepath.ToSlash(arg[i+len(".zip/"):]), true
+	}
+	return "", "", false
+}
+
+func loadFromZip(archive, inner string) (ROM, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return ROM{}, fmt.Errorf("open rom archive: %w", err)
+	}
+	defer r.Close()
+
+	var matches []*zip.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if inner != "" && f.Name == inner {
+			matches = []*zip.File{f}
+			break
+		}
+		if inner == "" && IsROM(f.Name) {
+			matches = append(matches, f)
+		}
+	}
+
+	switch {
+	case len(matches) == 0 && inner != "":
+		return ROM{}, fmt.Errorf("open rom archive %s: no file named %s", archive, inner)
+	case len(matches) == 0:
+		return ROM{}, fmt.Errorf("open rom archive %s: no roms inside", archive)
+	case len(matches) > 1:
+		var names []string
+		for _, f := range matches {
+			names = append(names, f.Name)
+		}
+		return ROM{}, fmt.Errorf("open rom archive %s: more than one rom, pick one with %s/NAME: %s", archive, archive, strings.Join(names, ", "))
+	}
+
+	f, err := matches[0].Open()
+	if err != nil {
+		return ROM{}, fmt.Errorf("open rom archive %s: %w", archive, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return ROM{}, fmt.Errorf("read %s from %s: %w", matches[0].Name, archive, err)
+	}
+	return ROM{Name: path.Base(matches[0].Name), Data: data}, nil
+}
+
+// IsROM reports whether the file name has one of the ROM Extensions
+func IsROM(name string) bool {
+	return slices.Contains(Extensions, strings.ToLower(path.Ext(name)))
+}
+
+// Scan returns the paths of the ROMs directly inside dir, sorted by name. Zip
+// archives are listed as "archive.zip/rom" for every ROM inside them.
+func Scan(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		switch {
+		case e.IsDir():
+			continue
+		case strings.EqualFold(filepath.Ext(e.Name()), ".zip"):
+			inner, err := scanZip(p)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, inner...)
+		case IsROM(e.Name()):
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+func scanZip(archive string) ([]string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open rom archive: %w", err)
+	}
+	defer r.Close()
+
+	var paths []string
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() && IsROM(f.Name) {
+			paths = append(paths, archive+"/"+f.Name)
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
@@ -0,0 +1,115 @@
+package romfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZip(t testing.TB, path string, files map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	rom := []byte{0x12, 0x00}
+	romPath := filepath.Join(dir, "loop.ch8")
+	if err := os.WriteFile(romPath, rom, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, filepath.Join(dir, "one.zip"), map[string][]byte{"readme.txt": []byte("hi"), "games/loop.ch8": rom})
+	writeZip(t, filepath.Join(dir, "two.zip"), map[string][]byte{"a.ch8": rom, "b.ch8": {0x00, 0xE0}})
+
+	cases := []struct {
+		name     string
+		arg      string
+		wantName string
+		wantData []byte
+		wantErr  string
+	}{
+		{name: "absolute path", arg: romPath, wantName: "loop.ch8", wantData: rom},
+		{name: "bare name from roms directory", arg: "ibm_logo.ch8", wantName: "ibm_logo.ch8"},
+		{name: "stdin", arg: Stdin, wantName: "stdin", wantData: rom},
+		{name: "archive with one rom", arg: filepath.Join(dir, "one.zip"), wantName: "loop.ch8", wantData: rom},
+		{name: "named rom in archive", arg: filepath.Join(dir, "two.zip", "b.ch8"), wantName: "b.ch8", wantData: []byte{0x00, 0xE0}},
+		{name: "archive with two roms", arg: filepath.Join(dir, "two.zip"), wantErr: "more than one rom"},
+		{name: "missing file in archive", arg: filepath.Join(dir, "two.zip", "c.ch8"), wantErr: "no file named c.ch8"},
+		{name: "missing file", arg: filepath.Join(dir, "missing.ch8"), wantErr: "no such file"},
+	}
+
+	// The bare name case looks in ./roms relative to the working directory
+	t.Chdir("..")
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Load(test.arg, bytes.NewReader(rom))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name != test.wantName {
+				t.Errorf("expected name %s, got %s", test.wantName, got.Name)
+			}
+			if test.wantData != nil && !bytes.Equal(got.Data, test.wantData) {
+				t.Errorf("expected data %X, got %X", test.wantData, got.Data)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.ch8", "a.C8", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{0x12, 0x00}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.ch8"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, filepath.Join(dir, "pack.zip"), map[string][]byte{"y.ch8": {0x12, 0x00}, "x.ch8": {0x12, 0x00}, "x.txt": nil})
+
+	got, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "a.C8"),
+		filepath.Join(dir, "b.ch8"),
+		filepath.Join(dir, "pack.zip") + "/x.ch8",
+		filepath.Join(dir, "pack.zip") + "/y.ch8",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// Every scanned path can be loaded
+	for _, p := range got {
+		if _, err := Load(p, nil); err != nil {
+			t.Errorf("could not load scanned rom %s: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Package tui runs the emulator in a terminal, for machines without a display
+// server. The display is drawn with Unicode block or braille characters, the
+// registers are shown on a status line and the keyboard is read in raw mode.
+//
+// Terminals only report key presses, not releases, so a key counts as held for a
+// few frames after its last press. Holding a key works through the terminal's key
+// repeat.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/host"
+	"golang.org/x/term"
+)
+
+// Mode is how pixels are turned into characters
+type Mode int
+
+const (
+	HalfBlocks Mode = iota // 1x2 pixels per character, needs 64x16 characters
+	Braille                // 2x4 pixels per character, needs 32x8 characters
+)
+
+// holdFrames is how long a key stays held after the terminal last reported it. It
+// covers the delay before the terminal's key repeat starts.
+const holdFrames = 30
+
+const ctrlC = 0x03
+
+// Options configure the terminal frontend
+type Options struct {
+	Tickrate int               // instructions per frame
+	Keymap   map[byte][]string // CHIP-8 key to input names, see Keys
+	Mode     Mode
+}
+
+// Run runs emu in the terminal until Ctrl-C is pressed. in must be a terminal, it
+// is put in raw mode while running.
+func Run(emu *chip8.Chip8, in *os.File, out io.Writer, opts Options) error {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return fmt.Errorf("terminal: %w", err)
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	fmt.Fprint(out, "\x1b[?25l\x1b[2J") // hide the cursor and clear the screen
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[0m\r\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t := &terminal{
+		out:   out,
+		mode:  opts.Mode,
+		keys:  Keys(opts.Keymap),
+		input: make(chan []byte),
+		quit:  cancel,
+	}
+	go readInput(ctx, in, t.input)
+
+	runner := host.NewRunner(emu, opts.Tickrate)
+	runner.Video, runner.Audio, runner.Input = t, t, t
+	return runner.Run(ctx)
+}
+
+// readInput sends what is typed on in to input until ctx is done or reading fails.
+// A read that is blocked when ctx ends returns with the next key, which is dropped.
+func readInput(ctx context.Context, in io.Reader, input chan<- []byte) {
+	defer close(input)
+	buf := make([]byte, 64)
+	for ctx.Err() == nil {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case input <- append([]byte(nil), buf[:n]...):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// terminal is the video and audio sink and the input source for the runner
+type terminal struct {
+	out       io.Writer
+	mode      Mode
+	keys      map[string][]byte // input name to CHIP-8 keys
+	input     chan []byte       // bytes read from the terminal
+	quit      context.CancelFunc
+	frame     int
+	heldUntil [16]int // frame each key is released on
+	beeping   bool
+	lines     []string // the display as last rendered
+}
+
+// Keys reads what was typed since the last frame
+func (t *terminal) Keys() []byte {
+	t.frame++
+drain:
+	for {
+		select {
+		case data, ok := <-t.input:
+			if !ok {
+				t.quit()
+				break drain
+			}
+			for _, name := range parseInput(data) {
+				if name == "Ctrl+C" {
+					t.quit()
+				}
+				for _, key := range t.keys[name] {
+					t.heldUntil[key] = t.frame + holdFrames
+				}
+			}
+		default:
+			break drain
+		}
+	}
+
+	var held []byte
+	for key, until := range t.heldUntil {
+		if until > t.frame {
+			held = append(held, byte(key))
+		}
+	}
+	return held
+}
+
+// SetBuzzer rings the terminal bell, the only sound there is, when the buzzer starts
+func (t *terminal) SetBuzzer(on bool) {
+	if on && !t.beeping {
+		fmt.Fprint(t.out, "\a")
+	}
+	t.beeping = on
+}
+
+// Present draws the display again only if it changed, the status line every frame
+func (t *terminal) Present(emu *chip8.Chip8) {
+	if t.lines == nil || !emu.Display.Dirty().Empty() {
+		t.lines = Render(emu, t.mode)
+	}
+	draw(t.out, t.lines, StatusLine(emu))
+}
+
+// draw moves the cursor to the top left and writes the screen and the status line.
+// Lines end in \r\n because the terminal is in raw mode.
+func draw(out io.Writer, lines []string, status string) {
+	var sb strings.Builder
+	sb.WriteString("\x1b[H")
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString(status)
+	sb.WriteString("\x1b[K") // clear the rest of a longer previous status
+	io.WriteString(out, sb.String())
+}
+
+// Render draws the display of emu in the given mode, one string per line
+func Render(emu *chip8.Chip8, mode Mode) []string {
+	width, height := emu.Display.Width(), emu.Display.Height()
+	lit := func(x, y int) bool {
+		return x < width && y < height && emu.Display.Lit(x, y)
+	}
+	if mode == Braille {
+		return braille(width, height, lit)
+	}
+	return halfBlocks(width, height, lit)
+}
+
+// halfBlocks draws two pixel rows per line with the upper and lower half blocks
+func halfBlocks(width, height int, lit func(x, y int) bool) []string {
+	var lines []string
+	for y := 0; y < height; y += 2 {
+		var sb strings.Builder
+		for x := range width {
+			top, bottom := lit(x, y), lit(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteRune('█')
+			case top:
+				sb.WriteRune('▀')
+			case bottom:
+				sb.WriteRune('▄')
+			default:
+				sb.WriteRune(' ')
+			}
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// brailleDots are the bits of the braille pattern for each pixel of a 2x4 cell
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// braille draws 2x4 pixels per character with the braille patterns
+func braille(width, height int, lit func(x, y int) bool) []string {
+	var lines []string
+	for y := 0; y < height; y += 4 {
+		var sb strings.Builder
+		for x := 0; x < width; x += 2 {
+			var r rune = 0x2800
+			for dy := range 4 {
+				for dx := range 2 {
+					if lit(x+dx, y+dy) {
+						r |= brailleDots[dy][dx]
+					}
+				}
+			}
+			sb.WriteRune(r)
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// StatusLine shows the program counter, index, timers and the V registers
+func StatusLine(emu *chip8.Chip8) string {
+	delay, sound := emu.Timers()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "PC %03X I %03X DT %02X ST %02X V", emu.PC, emu.Index, delay, sound)
+	for _, v := range emu.Registers {
+		fmt.Fprintf(&sb, " %02X", v)
+	}
+	if emu.WaitingForKey() {
+		sb.WriteString(" [waiting for key]")
+	}
+	return sb.String()
+}
+
+// escapes are the input names for the escape sequences of special keys
+var escapes = map[string]string{
+	"\x1b[A": "ArrowUp",
+	"\x1b[B": "ArrowDown",
+	"\x1b[C": "ArrowRight",
+	"\x1b[D": "ArrowLeft",
+	"\x1bOA": "ArrowUp",
+	"\x1bOB": "ArrowDown",
+	"\x1bOC": "ArrowRight",
+	"\x1bOD": "ArrowLeft",
+}
+
+// parseInput splits bytes read from the terminal into input names. Letters are
+// upper case as in the keymap ("Q"), other characters are themselves, except for
+// "Space", "Enter", "Ctrl+C" and the arrow keys.
+func parseInput(data []byte) []string {
+	var names []string
+	s := string(data)
+	for len(s) > 0 {
+		if s[0] == 0x1b {
+			matched := false
+			for seq, name := range escapes {
+				if strings.HasPrefix(s, seq) {
+					names = append(names, name)
+					s = s[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				names = append(names, "Escape")
+				s = s[1:]
+			}
+			continue
+		}
+		switch c := s[0]; {
+		case c == ctrlC:
+			names = append(names, "Ctrl+C")
+		case c == ' ':
+			names = append(names, "Space")
+		case c == '\r' || c == '\n':
+			names = append(names, "Enter")
+		case c >= 'a' && c <= 'z':
+			names = append(names, strings.ToUpper(string(c)))
+		default:
+			names = append(names, string(c))
+		}
+		s = s[1:]
+	}
+	return names
+}
+
+// Keys turns a keymap from the config into input names the terminal can report,
+// mapped to CHIP-8 keys. Gamepad and other keys a terminal can't tell apart are left out.
+func Keys(keymap map[byte][]string) map[string][]byte {
+	keys := map[string][]byte{}
+	for key, names := range keymap {
+		for _, name := range names {
+			if strings.HasPrefix(name, "Pad:") {
+				continue
+			}
+			name = strings.TrimPrefix(name, "Digit")
+			if len(name) == 1 {
+				name = strings.ToUpper(name)
+			}
+			keys[name] = append(keys[name], key)
+		}
+	}
+	return keys
+}
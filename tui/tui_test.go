@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomanta/echip8/chip8"
+)
+
+func TestRender(t *testing.T) {
+	emu, err := chip8.NewChip8FromByte([]byte{0x00, 0xE0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu.Display.Set(0, 0, 0, true)
+	emu.Display.Set(0, 1, 1, true)
+	emu.Display.Set(0, 2, 0, true)
+	emu.Display.Set(0, 2, 1, true)
+	emu.Display.Set(0, 63, 31, true)
+
+	lines := Render(emu, HalfBlocks)
+	if len(lines) != 16 {
+		t.Fatalf("expected 16 lines, got %d", len(lines))
+	}
+	if got := []rune(lines[0])[:3]; string(got) != "▀▄█" {
+		t.Errorf("expected the first line to start with ▀▄█, got %q", string(got))
+	}
+	if got := []rune(lines[15])[63]; got != '▄' {
+		t.Errorf("expected the bottom right pixel as ▄, got %q", got)
+	}
+
+	lines = Render(emu, Braille)
+	if len(lines) != 8 || len([]rune(lines[0])) != 32 {
+		t.Fatalf("expected 8 lines of 32 characters, got %d lines", len(lines))
+	}
+	// Pixels (0,0) and (1,1) in the first cell, (2,0) and (2,1) in the second
+	if got := []rune(lines[0])[:2]; string(got) != "⠑⠃" {
+		t.Errorf("expected ⠑⠃, got %q", string(got))
+	}
+	if got := []rune(lines[7])[31]; got != '⢀' {
+		t.Errorf("expected the bottom right pixel as ⢀, got %q", got)
+	}
+}
+
+func TestStatusLine(t *testing.T) {
+	emu, _ := chip8.NewChip8FromByte([]byte{0x6A, 0x42})
+	emu.RunFrame(1)
+	got := StatusLine(emu)
+	if !strings.HasPrefix(got, "PC 202 I 000 DT 00 ST 00 V") || !strings.Contains(got, " 42 00 00 00 00 00") {
+		t.Errorf("unexpected status line %q", got)
+	}
+}
+
+func TestParseInput(t *testing.T) {
+	got := parseInput([]byte("q1 \x1b[A\x1bx\x03"))
+	want := []string{"Q", "1", "Space", "ArrowUp", "Escape", "X", "Ctrl+C"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys(map[byte][]string{
+		0x5: {"W", "ArrowUp", "Pad:Up"},
+		0x1: {"Digit1"},
+		0x4: {"q"},
+	})
+	for name, want := range map[string]byte{"W": 0x5, "ArrowUp": 0x5, "1": 0x1, "Q": 0x4} {
+		if !slices.Contains(keys[name], want) {
+			t.Errorf("expected %s to map to key %X, got %v", name, want, keys[name])
+		}
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	r, w := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	input := make(chan []byte)
+	go readInput(ctx, r, input)
+
+	go w.Write([]byte("a"))
+	if got := <-input; string(got) != "a" {
+		t.Errorf("expected \"a\", got %q", got)
+	}
+
+	// Once cancelled the reader stops with the next key instead of blocking on the send
+	cancel()
+	go w.Write([]byte("b"))
+	select {
+	case _, ok := <-input:
+		for ok {
+			_, ok = <-input
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the reader to stop after the context ended")
+	}
+}
@@ -0,0 +1,245 @@
+//go:build js && wasm
+
+package web
+
+import (
+	"fmt"
+	"strconv"
+	"syscall/js"
+
+	"github.com/tomanta/echip8/host"
+)
+
+// frameMillis is the length of a 60 Hz frame
+const frameMillis = 1000.0 / host.FrameRate
+
+// maxCatchUp limits the frames run for one animation frame, after the tab was in
+// the background for example
+const maxCatchUp = 4
+
+// page is the frontend bound to the elements of index.html
+type page struct {
+	doc     js.Value
+	canvas  js.Value
+	ctx     js.Value
+	status  js.Value
+	image   js.Value // ImageData the size of the display
+	session *Session
+	audio   *buzzer
+	last    float64 // time of the last frame run, from requestAnimationFrame
+}
+
+// Start binds the frontend to the page: the canvas with id "screen", the file input
+// with id "rom", the element with id "status" and the buttons with a data-key
+// attribute that make up the on-screen keypad. ROMs can also be dropped anywhere
+// on the page.
+func Start() {
+	doc := js.Global().Get("document")
+	p := &page{
+		doc:    doc,
+		canvas: doc.Call("getElementById", "screen"),
+		status: doc.Call("getElementById", "status"),
+		audio:  &buzzer{},
+	}
+	p.ctx = p.canvas.Call("getContext", "2d")
+
+	doc.Call("getElementById", "rom").Call("addEventListener", "change", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if files := this.Get("files"); files.Length() > 0 {
+			p.loadFile(files.Index(0))
+		}
+		return nil
+	}))
+
+	body := doc.Get("body")
+	body.Call("addEventListener", "dragover", js.FuncOf(func(this js.Value, args []js.Value) any {
+		args[0].Call("preventDefault")
+		return nil
+	}))
+	body.Call("addEventListener", "drop", js.FuncOf(func(this js.Value, args []js.Value) any {
+		args[0].Call("preventDefault")
+		if files := args[0].Get("dataTransfer").Get("files"); files.Length() > 0 {
+			p.loadFile(files.Index(0))
+		}
+		return nil
+	}))
+
+	doc.Call("addEventListener", "keydown", js.FuncOf(func(this js.Value, args []js.Value) any {
+		p.audio.start()
+		if p.session != nil && p.session.KeyDown(args[0].Get("code").String()) {
+			args[0].Call("preventDefault")
+		}
+		return nil
+	}))
+	doc.Call("addEventListener", "keyup", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if p.session != nil && p.session.KeyUp(args[0].Get("code").String()) {
+			args[0].Call("preventDefault")
+		}
+		return nil
+	}))
+
+	buttons := doc.Call("querySelectorAll", "[data-key]")
+	for i := range buttons.Length() {
+		p.bindKeypadButton(buttons.Index(i))
+	}
+
+	var frame js.Func
+	frame = js.FuncOf(func(this js.Value, args []js.Value) any {
+		p.animate(args[0].Float())
+		js.Global().Call("requestAnimationFrame", frame)
+		return nil
+	})
+	js.Global().Call("requestAnimationFrame", frame)
+	p.setStatus("Choose or drop a ROM")
+}
+
+// bindKeypadButton makes an on-screen keypad button hold its key while pressed
+func (p *page) bindKeypadButton(button js.Value) {
+	key, err := strconv.ParseUint(button.Get("dataset").Get("key").String(), 16, 8)
+	if err != nil {
+		return
+	}
+	press := func(down bool) js.Func {
+		return js.FuncOf(func(this js.Value, args []js.Value) any {
+			args[0].Call("preventDefault")
+			p.audio.start()
+			if p.session != nil {
+				p.session.Touch(byte(key), down)
+			}
+			return nil
+		})
+	}
+	button.Call("addEventListener", "pointerdown", press(true))
+	for _, event := range []string{"pointerup", "pointercancel", "pointerleave"} {
+		button.Call("addEventListener", event, press(false))
+	}
+}
+
+// loadFile reads a File from the file input or a drop and boots it
+func (p *page) loadFile(file js.Value) {
+	p.audio.start()
+	name := file.Get("name").String()
+	var loaded js.Func
+	loaded = js.FuncOf(func(this js.Value, args []js.Value) any {
+		defer loaded.Release()
+		data := js.Global().Get("Uint8Array").New(args[0])
+		rom := make([]byte, data.Length())
+		js.CopyBytesToGo(rom, data)
+		p.boot(name, rom)
+		return nil
+	})
+	file.Call("arrayBuffer").Call("then", loaded)
+}
+
+func (p *page) boot(name string, rom []byte) {
+	s, err := NewSession(name, rom)
+	if err != nil {
+		p.setStatus(err.Error())
+		return
+	}
+	p.session = s
+	p.audio.configure(s.Settings.Audio.Enabled, s.Settings.Audio.Frequency, s.Settings.Audio.Volume)
+	width, height := s.Size()
+	p.canvas.Set("width", width)
+	p.canvas.Set("height", height)
+	p.image = p.ctx.Call("createImageData", width, height)
+	p.last = 0
+	title := s.Settings.Window.Title
+	if title == "" {
+		title = name
+	}
+	p.setStatus(fmt.Sprintf("%s (%s, %d instructions per frame)", title, s.Settings.Platform, s.Settings.Tickrate))
+	p.draw()
+}
+
+// animate runs the frames due since the last animation frame and draws the display
+func (p *page) animate(now float64) {
+	if p.session == nil {
+		return
+	}
+	if p.last == 0 {
+		p.last = now - frameMillis
+	}
+	ran := 0
+	for p.last+frameMillis <= now && ran < maxCatchUp {
+		if err := p.session.Frame(); err != nil {
+			p.setStatus(err.Error())
+			p.session.Runner().SetPaused(true)
+			break
+		}
+		p.last += frameMillis
+		ran++
+	}
+	if ran == maxCatchUp {
+		p.last = now
+	}
+	p.audio.set(p.session.Buzzer())
+	if ran > 0 {
+		p.draw()
+	}
+}
+
+func (p *page) draw() {
+	js.CopyBytesToJS(p.image.Get("data"), p.session.Pixels())
+	p.ctx.Call("putImageData", p.image, 0, 0)
+}
+
+func (p *page) setStatus(text string) {
+	if p.status.Truthy() {
+		p.status.Set("textContent", text)
+	}
+}
+
+// buzzer is a square wave from Web Audio. Browsers only allow audio after the user
+// has interacted with the page, so it is started on the first key press or ROM load.
+type buzzer struct {
+	ctx       js.Value
+	osc       js.Value
+	gain      js.Value
+	enabled   bool
+	frequency float64
+	volume    float64
+	on        bool
+}
+
+func (b *buzzer) start() {
+	if b.ctx.Truthy() {
+		return
+	}
+	audioContext := js.Global().Get("AudioContext")
+	if !audioContext.Truthy() {
+		return
+	}
+	b.ctx = audioContext.New()
+	b.osc = b.ctx.Call("createOscillator")
+	b.osc.Set("type", "square")
+	b.gain = b.ctx.Call("createGain")
+	b.gain.Get("gain").Set("value", 0)
+	b.osc.Call("connect", b.gain)
+	b.gain.Call("connect", b.ctx.Get("destination"))
+	b.osc.Call("start")
+	b.apply()
+}
+
+func (b *buzzer) configure(enabled bool, frequency, volume float64) {
+	b.enabled, b.frequency, b.volume = enabled, frequency, volume
+	b.apply()
+}
+
+func (b *buzzer) set(on bool) {
+	if on != b.on {
+		b.on = on
+		b.apply()
+	}
+}
+
+func (b *buzzer) apply() {
+	if !b.ctx.Truthy() {
+		return
+	}
+	b.osc.Get("frequency").Set("value", b.frequency)
+	volume := 0.0
+	if b.enabled && b.on {
+		volume = b.volume
+	}
+	b.gain.Get("gain").Call("setValueAtTime", volume, b.ctx.Get("currentTime"))
+}
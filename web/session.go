@@ -0,0 +1,170 @@
+// Package web is the browser frontend. Session holds a running ROM and everything
+// that doesn't touch the page, so it can be tested on any platform; the DOM and
+// Web Audio bindings are in dom.go, which only builds for js/wasm.
+package web
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/config"
+	"github.com/tomanta/echip8/host"
+	"github.com/tomanta/echip8/palette"
+	"github.com/tomanta/echip8/romdb"
+)
+
+// Session is a ROM running in the page. It is the video and audio sink and the
+// input source of its runner.
+type Session struct {
+	Name     string
+	Settings config.Settings
+	emu      *chip8.Chip8
+	runner   *host.Runner
+	keys     map[string][]byte // KeyboardEvent.code to CHIP-8 keys
+	down     map[string]bool   // codes held on the keyboard
+	touched  [16]bool          // keys held on the on-screen keypad
+	buzzer   bool
+	palette  palette.Palette
+	width    int
+	height   int
+	pixels   []byte // RGBA, the layout of canvas ImageData
+}
+
+// NewSession boots rom with the settings from the ROM database. There are no
+// config files in the browser.
+func NewSession(name string, rom []byte) (*Session, error) {
+	settings, err := config.Resolve("", name, "", romdb.Lookup(rom))
+	if err != nil {
+		return nil, err
+	}
+	emu, err := chip8.New(rom, chip8.WithQuirks(settings.Quirks), chip8.WithFont(settings.Font), chip8.WithLayout(settings.Layout))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	s := &Session{
+		Name:     name,
+		Settings: settings,
+		emu:      emu,
+		keys:     Keys(settings.Keymap),
+		down:     map[string]bool{},
+		palette:  settings.Palette,
+	}
+	s.runner = host.NewRunner(s.emu, settings.Tickrate)
+	s.runner.Video, s.runner.Audio, s.runner.Input = s, s, s
+	s.Present(s.emu)
+	return s, nil
+}
+
+// Frame runs one 60 Hz frame
+func (s *Session) Frame() error {
+	return s.runner.Frame()
+}
+
+// Runner returns the runner, for pausing and changing the speed
+func (s *Session) Runner() *host.Runner {
+	return s.runner
+}
+
+// KeyDown handles a keydown event and reports whether the key is mapped, in which
+// case the page should not act on it
+func (s *Session) KeyDown(code string) bool {
+	if _, ok := s.keys[code]; !ok {
+		return false
+	}
+	s.down[code] = true
+	return true
+}
+
+// KeyUp handles a keyup event
+func (s *Session) KeyUp(code string) bool {
+	if _, ok := s.keys[code]; !ok {
+		return false
+	}
+	delete(s.down, code)
+	return true
+}
+
+// Touch presses or releases a key of the on-screen keypad
+func (s *Session) Touch(key byte, down bool) {
+	s.touched[key&0x0F] = down
+}
+
+// Keys returns the keys held on the keyboard or the on-screen keypad
+func (s *Session) Keys() []byte {
+	held := s.touched
+	for code := range s.down {
+		for _, key := range s.keys[code] {
+			held[key] = true
+		}
+	}
+	var keys []byte
+	for key, on := range held {
+		if on {
+			keys = append(keys, byte(key))
+		}
+	}
+	return keys
+}
+
+func (s *Session) SetBuzzer(on bool) {
+	s.buzzer = on
+}
+
+// Buzzer reports whether the buzzer is sounding
+func (s *Session) Buzzer() bool {
+	return s.buzzer
+}
+
+// Present converts the part of the display that changed to RGBA pixels
+func (s *Session) Present(emu *chip8.Chip8) {
+	s.width, s.height = emu.Display.Width(), emu.Display.Height()
+	area := emu.Display.Dirty()
+	if len(s.pixels) != s.width*s.height*4 {
+		s.pixels = make([]byte, s.width*s.height*4)
+		area = image.Rect(0, 0, s.width, s.height)
+	}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			c := s.palette.Pixel(emu.Display.Pixel(x, y))
+			i := (y*s.width + x) * 4
+			s.pixels[i], s.pixels[i+1], s.pixels[i+2], s.pixels[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+}
+
+// Pixels returns the display as RGBA pixels, row by row
+func (s *Session) Pixels() []byte {
+	return s.pixels
+}
+
+// Size returns the resolution of the display
+func (s *Session) Size() (width, height int) {
+	return s.width, s.height
+}
+
+// Keys turns a keymap from the config into KeyboardEvent.code values mapped to
+// CHIP-8 keys: "Q" becomes "KeyQ" and "1" becomes "Digit1". Gamepad inputs are left out.
+func Keys(keymap map[byte][]string) map[string][]byte {
+	keys := map[string][]byte{}
+	for key, names := range keymap {
+		for _, name := range names {
+			if strings.HasPrefix(name, "Pad:") {
+				continue
+			}
+			code := name
+			if len(name) == 1 {
+				switch c := strings.ToUpper(name)[0]; {
+				case c >= 'A' && c <= 'Z':
+					code = "Key" + string(c)
+				case c >= '0' && c <= '9':
+					code = "Digit" + string(c)
+				}
+			}
+			keys[code] = append(keys[code], key)
+		}
+	}
+	return keys
+}
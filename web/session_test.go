@@ -0,0 +1,109 @@
+package web
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/tomanta/echip8/chip8"
+	"github.com/tomanta/echip8/palette"
+)
+
+func TestKeys(t *testing.T) {
+	keys := Keys(map[byte][]string{
+		0x5: {"W", "ArrowUp", "Pad:Up"},
+		0x1: {"1"},
+		0x0: {"Digit0", "Space"},
+	})
+	for code, want := range map[string]byte{"KeyW": 0x5, "ArrowUp": 0x5, "Digit1": 0x1, "Digit0": 0x0, "Space": 0x0} {
+		if !slices.Contains(keys[code], want) {
+			t.Errorf("expected %s to map to key %X, got %v", code, want, keys[code])
+		}
+	}
+	if _, ok := keys["Pad:Up"]; ok {
+		t.Errorf("expected gamepad inputs to be left out")
+	}
+}
+
+func TestSession(t *testing.T) {
+	// V1 = 5, wait for key 5 to be held and draw the font sprite for 0 at 0,0
+	rom := []byte{
+		0x61, 0x05, // LD V1, 5
+		0xE1, 0xA1, // SKNP V1
+		0x12, 0x08, // JP 0x208
+		0x12, 0x02, // JP 0x202
+		0xA0, 0x50, // LD I, 0x050 (the font sprite for 0)
+		0xD0, 0x05, // DRW V0, V0, 5
+		0x12, 0x0C, // JP 0x20C
+	}
+	s, err := NewSession("test.ch8", rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := s.Size(); w != 64 || h != 32 || len(s.Pixels()) != 64*32*4 {
+		t.Fatalf("expected 64x32 RGBA pixels, got %dx%d with %d bytes", w, h, len(s.Pixels()))
+	}
+
+	s.Frame()
+	if s.emu.PC > 0x206 {
+		t.Fatalf("expected the ROM to wait for key 5, PC is 0x%03X", s.emu.PC)
+	}
+
+	if !s.KeyDown("KeyW") {
+		t.Fatalf("expected KeyW to be mapped")
+	}
+	if s.KeyDown("F5") {
+		t.Errorf("expected F5 not to be mapped")
+	}
+	s.Frame()
+	s.KeyUp("KeyW")
+	if s.emu.PC < 0x208 {
+		t.Fatalf("expected the key to be seen, PC is 0x%03X", s.emu.PC)
+	}
+
+	fg := palette.Default().Foreground()
+	got := s.Pixels()[:4]
+	if got[0] != fg.R || got[1] != fg.G || got[2] != fg.B || got[3] != fg.A {
+		t.Errorf("expected the top left pixel drawn in %v, got %v", fg, got)
+	}
+}
+
+func TestSessionTouch(t *testing.T) {
+	s, err := NewSession("test.ch8", []byte{0x12, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Touch(0xA, true)
+	s.KeyDown("KeyZ") // also key A in the default keymap
+	s.Touch(0xA, false)
+	if keys := s.Keys(); !slices.Equal(keys, []byte{0xA}) {
+		t.Errorf("expected key A held by the keyboard, got %v", keys)
+	}
+	s.KeyUp("KeyZ")
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+}
+
+func TestSessionPlanes(t *testing.T) {
+	s, err := NewSession("test.ch8", []byte{0x12, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := s.emu.Clone()
+	emu.Display = chip8.NewFramebuffer(64, 32, 2)
+	emu.Display.Set(0, 1, 0, true)
+	emu.Display.Set(1, 2, 0, true)
+	emu.Display.Set(0, 3, 0, true)
+	emu.Display.Set(1, 3, 0, true)
+	s.Present(emu)
+
+	want := palette.Default().Colors
+	for x := range 4 {
+		i := x * 4
+		got := color.RGBA{s.Pixels()[i], s.Pixels()[i+1], s.Pixels()[i+2], s.Pixels()[i+3]}
+		if got != want[x] {
+			t.Errorf("pixel %d: expected palette colour %v, got %v", x, want[x], got)
+		}
+	}
+}